@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// A TraceLog is a timestamped message recorded against a trace event with
+// [Event].
+type TraceLog struct {
+	// Time is when the message was recorded.
+	Time time.Time `json:"time"`
+
+	// Message is the message text.
+	Message string `json:"message"`
+
+	// Attrs holds the message's attributes, if any.
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// Annotate attaches an attribute to the trace event of the innermost [Named]
+// step or [Retry] attempt, such as the number of rows a migration step copied
+// or the region a step selected. Annotating the same key again replaces the
+// value.
+//
+// Values are resolved as by [slog.AnyValue], so integers are stored as int64,
+// and [slog.LogValuer] values are resolved. Attributes are recorded in
+// [TraceEvent.Attrs] and included in JSON output, so values should be
+// encodable as JSON.
+//
+// Annotate does nothing if tracing is not enabled (see [Traced]) or there is
+// no enclosing traced step.
+//
+// Example:
+//
+//	func MigrateRows() flow.Step[*State] {
+//	    return flow.Named("migrate-rows", func(ctx context.Context, s *State) error {
+//	        n, err := s.db.CopyRows(ctx)
+//	        flow.Annotate(ctx, "rows", n)
+//	        return err
+//	    })
+//	}
+func Annotate(ctx context.Context, key string, value any) {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if !ok || f.trace == nil || f.event == noEvent {
+		return
+	}
+	f.trace.recordAttr(f.event, key, attrValue(slog.AnyValue(value)))
+}
+
+// Event records a timestamped message, with optional attributes, against the
+// trace event of the innermost [Named] step or [Retry] attempt. Messages are
+// recorded in [TraceEvent.Logs], in the order they were recorded.
+//
+// Event does nothing if tracing is not enabled (see [Traced]) or there is no
+// enclosing traced step.
+//
+// Example:
+//
+//	flow.Event(ctx, "selected region",
+//	    slog.String("region", region),
+//	    slog.Int("candidates", len(regions)),
+//	)
+func Event(ctx context.Context, msg string, attrs ...slog.Attr) {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if !ok || f.trace == nil || f.event == noEvent {
+		return
+	}
+	log := TraceLog{
+		Time:    f.clock.Now(),
+		Message: msg,
+		Attrs:   attrMap(attrs),
+	}
+	f.trace.recordLog(f.event, log)
+}
+
+// attrMap converts attributes to a map, or nil if there are none.
+func attrMap(attrs []slog.Attr) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(attrs))
+	for _, attr := range attrs {
+		m[attr.Key] = attrValue(attr.Value)
+	}
+	return m
+}
+
+// attrValue converts a slog value to the value stored in a trace. Groups
+// become maps.
+func attrValue(v slog.Value) any {
+	v = v.Resolve()
+	if v.Kind() == slog.KindGroup {
+		return attrMap(v.Group())
+	}
+	return v.Any()
+}
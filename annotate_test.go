@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnotate(t *testing.T) {
+	t.Parallel()
+
+	migrate := Named("migrate", Do(
+		Named("copy", func(ctx context.Context, _ *CountingFlow) error {
+			Annotate(ctx, "rows", 12000)
+			Annotate(ctx, "table", "users")
+			Annotate(ctx, "table", "accounts")
+			Event(ctx, "batch done", slog.Int("batch", 1), slog.Group("timing", slog.Duration("took", time.Second)))
+			Event(ctx, "done")
+			return nil
+		}),
+		func(ctx context.Context, _ *CountingFlow) error {
+			// Unnamed steps annotate the innermost named step.
+			Annotate(ctx, "region", "us-east-2")
+			return nil
+		},
+	))
+
+	t.Run("Recorded", func(t *testing.T) {
+		t.Parallel()
+		var stream bytes.Buffer
+		trace, err := Traced(migrate, WithStreamTo(&stream))(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		copyEvent := trace.FindEvent(NameMatches("copy"))
+		if copyEvent == nil {
+			t.Fatal("expected a copy event")
+		}
+		if copyEvent.Attrs["rows"] != int64(12000) || copyEvent.Attrs["table"] != "accounts" {
+			t.Errorf("unexpected attributes: %v", copyEvent.Attrs)
+		}
+		if len(copyEvent.Logs) != 2 || copyEvent.Logs[0].Message != "batch done" || copyEvent.Logs[1].Attrs != nil {
+			t.Fatalf("unexpected logs: %+v", copyEvent.Logs)
+		}
+		timing, _ := copyEvent.Logs[0].Attrs["timing"].(map[string]any)
+		if copyEvent.Logs[0].Attrs["batch"] != int64(1) || timing["took"] != time.Second {
+			t.Errorf("unexpected log attributes: %v", copyEvent.Logs[0].Attrs)
+		}
+		if copyEvent.Logs[0].Time.Before(copyEvent.Start) {
+			t.Errorf("expected log time after the step started")
+		}
+
+		if e := trace.FindEvent(AttrEquals("region", "us-east-2")); e == nil || e.Names[len(e.Names)-1] != "migrate" {
+			t.Errorf("expected region on migrate, got %+v", e)
+		}
+		if n := len(trace.Filter(HasAttr("rows")).Events); n != 1 {
+			t.Errorf("expected 1 event with rows, got %d", n)
+		}
+		if trace.FindEvent(AttrEquals("rows", 12000)) == nil {
+			t.Error("expected rows to match an int")
+		}
+
+		// Attributes are streamed and survive a JSON round trip.
+		var decoded TraceEvent
+		line, _, _ := strings.Cut(stream.String(), "\n")
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Attrs["rows"] != float64(12000) || len(decoded.Logs) != 2 {
+			t.Errorf("unexpected streamed event: %s", line)
+		}
+		decodedTrace := &Trace{Events: []TraceEvent{decoded}}
+		if decodedTrace.FindEvent(AttrEquals("rows", 12000)) == nil {
+			t.Error("expected decoded rows to match an int")
+		}
+
+		var text bytes.Buffer
+		if _, err := trace.WriteText(&text); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(text.String(), "{rows=12000 table=accounts}") {
+			t.Errorf("expected attributes in text output, got:\n%s", text.String())
+		}
+	})
+
+	t.Run("Untraced", func(t *testing.T) {
+		t.Parallel()
+		runStepTest(t, migrate, 0, isNil)
+		Annotate(t.Context(), "key", "value")
+		Event(t.Context(), "message")
+	})
+
+	t.Run("NoNamedStep", func(t *testing.T) {
+		t.Parallel()
+		trace, err := Traced(func(ctx context.Context, _ *CountingFlow) error {
+			Annotate(ctx, "key", "value")
+			Event(ctx, "message")
+			return nil
+		})(t.Context(), &CountingFlow{})
+		if err != nil || len(trace.Events) != 0 {
+			t.Errorf("expected no events, got %+v, %v", trace.Events, err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sam-fredrickson/flow"
+)
+
+// =============================================================================
+// Collection Benchmarks
+// =============================================================================
+
+// service is a collection element that is neither a string nor a
+// fmt.Stringer, so checkpoint paths key it by index.
+type service struct {
+	name string
+	port int
+}
+
+// services returns n collection elements.
+func services(n int) []service {
+	items := make([]service, n)
+	for i := range items {
+		items[i] = service{name: fmt.Sprintf("svc-%d", i), port: 8000 + i}
+	}
+	return items
+}
+
+// Benchmark ForEach and Apply over 100 elements, with and without
+// checkpointing, which keeps each element's key in the step path. The steps
+// are not named, so that nothing is recorded and every run does the same
+// work.
+func BenchmarkCollections(b *testing.B) {
+	items := services(100)
+	deploy := func(service) flow.Step[*CountingFlow] { return Increment(1) }
+	save := func(_ context.Context, c *CountingFlow, _ service) error {
+		c.Counter++
+		return nil
+	}
+	workflows := []struct {
+		name string
+		step flow.Step[*CountingFlow]
+	}{
+		{"ForEach", flow.InSerial(flow.ForEach(flow.Value[*CountingFlow](items), deploy))},
+		{"Apply", flow.With(flow.Value[*CountingFlow](items), flow.Apply(save))},
+	}
+
+	for _, w := range workflows {
+		b.Run(w.name, func(b *testing.B) {
+			b.Run("Plain", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := w.step(b.Context(), &CountingFlow{}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run("Checkpointed", func(b *testing.B) {
+				step := flow.Checkpointed(flow.NewMemoryCheckpointStore(), w.step)
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := step(b.Context(), &CountingFlow{}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,484 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A CheckpointStore records which steps of a [Checkpointed] workflow have
+// completed, so that a rerun can skip them.
+//
+// Steps are identified by their checkpoint path: the names of the enclosing
+// [Named] steps within the Checkpointed workflow, interleaved with the item
+// keys of enclosing collection elements (see [Checkpointed]).
+//
+// Implementations must be safe for concurrent use, since parallel steps
+// complete concurrently.
+type CheckpointStore interface {
+	// Fingerprint returns the workflow fingerprint recorded with the
+	// checkpoints, or "" if none has been recorded.
+	Fingerprint(ctx context.Context) (string, error)
+
+	// SetFingerprint records the workflow fingerprint.
+	SetFingerprint(ctx context.Context, fingerprint string) error
+
+	// Completed reports whether the step at path has completed.
+	Completed(ctx context.Context, path []string) (bool, error)
+
+	// MarkCompleted records that the step at path has completed.
+	MarkCompleted(ctx context.Context, path []string) error
+
+	// Invalidate forgets the step at path, all steps within it, and all
+	// steps enclosing it, so that they run again on the next run. An empty
+	// path forgets every checkpoint and the fingerprint.
+	Invalidate(ctx context.Context, path []string) error
+}
+
+// FingerprintMismatchError is returned by [Checkpointed] when the
+// fingerprint recorded in the store differs from the workflow's current one.
+type FingerprintMismatchError struct {
+	// Stored is the fingerprint recorded in the store.
+	Stored string
+
+	// Current is the fingerprint of the workflow being run.
+	Current string
+}
+
+func (e *FingerprintMismatchError) Error() string {
+	return fmt.Sprintf(
+		"checkpoint fingerprint mismatch: stored %q, current %q",
+		e.Stored, e.Current,
+	)
+}
+
+// SkipReasonCheckpointed is the [TraceEvent.SkipReason] recorded for steps
+// skipped because a checkpoint shows that they already completed.
+const SkipReasonCheckpointed = "checkpointed"
+
+// CheckpointOption configures [Checkpointed].
+type CheckpointOption func(*checkpointOptions)
+
+// checkpointOptions holds configuration for checkpointing.
+type checkpointOptions struct {
+	// Fingerprint identifies the version of the workflow. If non-empty,
+	// resuming from checkpoints recorded with a different fingerprint fails.
+	Fingerprint string
+}
+
+// WithFingerprint sets the version fingerprint of a [Checkpointed] workflow.
+//
+// The fingerprint is recorded in the store on the first run. Later runs with
+// a different fingerprint refuse to resume, failing with a
+// *[FingerprintMismatchError], since checkpoints recorded by another version
+// of the workflow may not be meaningful. Invalidate the whole store to start
+// afresh.
+func WithFingerprint(fingerprint string) CheckpointOption {
+	return func(opts *checkpointOptions) {
+		opts.Fingerprint = fingerprint
+	}
+}
+
+// CheckpointKeyer is implemented by collection elements that provide their
+// own key for checkpoint paths. See [Checkpointed].
+type CheckpointKeyer interface {
+	CheckpointKey() string
+}
+
+// Checkpointed runs a workflow, skipping the [Named] steps that completed in
+// a previous run.
+//
+// Each [Named] and [NamedConsume] step that succeeds is recorded in store
+// under its checkpoint path. When the workflow is run again, steps whose path
+// is recorded are skipped without running, and, if tracing is enabled, a
+// trace event is recorded with [TraceEvent.SkipReason] set to
+// [SkipReasonCheckpointed]. [NamedExtract] and [NamedTransform] always run,
+// since their results are needed. Only the first step with a given path in a
+// run may be skipped; repeats of the path within a run, such as the
+// iterations of a [While] loop, always run.
+//
+// The checkpoint path is relative to the Checkpointed step (or to an
+// enclosing [Journaled] step, whichever is outermost). Within
+// collections ([ForEach], [Map], [Render], and [Apply]), it includes the key
+// of the element being processed, formatted as "[key]". The key is taken
+// from [CheckpointKeyer], a string element, a [fmt.Stringer], or an integer
+// element, in that order; other elements use "#" and their index. For
+// example, a step named "deploy" for the service "api" within a step named
+// "services" has the path ["services", "[api]", "deploy"].
+//
+// Checkpoint store failures fail the step that was being checked or
+// recorded.
+//
+// Example:
+//
+//	store, err := flow.NewFileCheckpointStore("setup.checkpoints.jsonl")
+//	if err != nil {
+//	    return err
+//	}
+//	err = flow.Checkpointed(store, SetupEnvironment(),
+//	    flow.WithFingerprint("setup-v3"),
+//	)(ctx, state)
+func Checkpointed[T any](
+	store CheckpointStore,
+	step Step[T],
+	opts ...CheckpointOption,
+) Step[T] {
+	options := checkpointOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(ctx context.Context, t T) error {
+		if options.Fingerprint != "" {
+			stored, err := store.Fingerprint(ctx)
+			if err != nil {
+				return err
+			}
+			switch stored {
+			case options.Fingerprint:
+			case "":
+				if err := store.SetFingerprint(ctx, options.Fingerprint); err != nil {
+					return err
+				}
+			default:
+				return &FingerprintMismatchError{
+					Stored:  stored,
+					Current: options.Fingerprint,
+				}
+			}
+		}
+
+		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+		f2 := newFlowCtx(ctx, f)
+		f2.checkpoints = &checkpointer{store: store, seen: make(map[string]bool)}
+		return step(f2, t)
+	}
+}
+
+// checkpointer tracks the checkpoints of a single run of a Checkpointed
+// workflow.
+type checkpointer struct {
+	store CheckpointStore
+
+	// seen holds the paths encountered so far in this run. Only the first
+	// step with a given path may be skipped: later ones, such as loop
+	// iterations, must run even though the first one has been recorded as
+	// completed.
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// skip reports whether the step at path completed in a previous run.
+func (c *checkpointer) skip(ctx context.Context, path []string) (bool, error) {
+	key := pathKey(path)
+	c.mu.Lock()
+	seen := c.seen[key]
+	c.seen[key] = true
+	c.mu.Unlock()
+	if seen {
+		return false, nil
+	}
+	return c.store.Completed(ctx, path)
+}
+
+// completedBefore reports whether the step named name, run within the step
+// of ctx, completed in a previous run of the enclosing Checkpointed workflow,
+// and so will be skipped. Unlike skip, it does not count as reaching the
+// step.
+func completedBefore(ctx context.Context, name string) (bool, error) {
+	f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if f == nil || f.checkpoints == nil {
+		return false, nil
+	}
+	path := append(slices.Clone(f.stepPath), name)
+	return f.checkpoints.store.Completed(ctx, path)
+}
+
+// pathKey joins a step path into a map key.
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// itemKey formats the checkpoint path element for a collection element.
+func itemKey(index int, item any) string {
+	var key string
+	switch v := item.(type) {
+	case CheckpointKeyer:
+		key = v.CheckpointKey()
+	case string:
+		key = v
+	case fmt.Stringer:
+		key = v.String()
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		key = fmt.Sprint(v)
+	default:
+		key = "#" + strconv.Itoa(index)
+	}
+	return "[" + key + "]"
+}
+
+// overlaps reports whether a and b are on the same branch of the checkpoint
+// tree, that is, whether one is a prefix of the other.
+func overlaps(a, b []string) bool {
+	n := min(len(a), len(b))
+	return slices.Equal(a[:n], b[:n])
+}
+
+// checkpointState is the data held by the built-in checkpoint stores.
+type checkpointState struct {
+	fingerprint string
+
+	// completed holds the completed paths in the order they completed, and
+	// done their keys (see pathKey).
+	completed [][]string
+	done      map[string]bool
+}
+
+func (s *checkpointState) isCompleted(path []string) bool {
+	return s.done[pathKey(path)]
+}
+
+func (s *checkpointState) markCompleted(path []string) bool {
+	key := pathKey(path)
+	if s.done[key] {
+		return false
+	}
+	if s.done == nil {
+		s.done = make(map[string]bool)
+	}
+	s.done[key] = true
+	s.completed = append(s.completed, slices.Clone(path))
+	return true
+}
+
+func (s *checkpointState) invalidate(path []string) {
+	if len(path) == 0 {
+		*s = checkpointState{}
+		return
+	}
+	s.completed = slices.DeleteFunc(s.completed, func(p []string) bool {
+		if overlaps(p, path) {
+			delete(s.done, pathKey(p))
+			return true
+		}
+		return false
+	})
+}
+
+// MemoryCheckpointStore is a [CheckpointStore] that keeps checkpoints in
+// memory. It is useful for tests and for resuming within a single process.
+type MemoryCheckpointStore struct {
+	mu    sync.Mutex
+	state checkpointState
+}
+
+// NewMemoryCheckpointStore creates an empty [MemoryCheckpointStore].
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{}
+}
+
+// Fingerprint returns the recorded workflow fingerprint, or "" if none has
+// been recorded.
+func (s *MemoryCheckpointStore) Fingerprint(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.fingerprint, nil
+}
+
+// SetFingerprint records the workflow fingerprint.
+func (s *MemoryCheckpointStore) SetFingerprint(_ context.Context, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.fingerprint = fingerprint
+	return nil
+}
+
+// Completed reports whether the step at path has completed.
+func (s *MemoryCheckpointStore) Completed(_ context.Context, path []string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.isCompleted(path), nil
+}
+
+// MarkCompleted records that the step at path has completed.
+func (s *MemoryCheckpointStore) MarkCompleted(_ context.Context, path []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.markCompleted(path)
+	return nil
+}
+
+// Invalidate forgets the step at path, all steps within it, and all steps
+// enclosing it. An empty path forgets every checkpoint and the fingerprint.
+func (s *MemoryCheckpointStore) Invalidate(_ context.Context, path []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.invalidate(path)
+	return nil
+}
+
+// checkpointRecord is a single line of a checkpoint file: either the
+// fingerprint or a completed path.
+type checkpointRecord struct {
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	Completed   []string `json:"completed,omitempty"`
+}
+
+// FileCheckpointStore is a [CheckpointStore] that keeps checkpoints in a
+// file of JSON Lines.
+//
+// Each completed step appends a record to the file, which is synced after
+// each record. Setting the fingerprint and invalidating checkpoints rewrite
+// the file atomically (by writing a temporary file and renaming it). A record
+// left half-written by a crash is ignored when the file is opened.
+type FileCheckpointStore struct {
+	mu    sync.Mutex
+	path  string
+	state checkpointState
+}
+
+// NewFileCheckpointStore opens the checkpoint file at path, creating an empty
+// store if the file does not exist.
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{path: path}
+	// #nosec G304 -- the checkpoint file is chosen by the caller
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record checkpointRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			if i == len(lines)-1 {
+				// The last record was cut short by a crash.
+				break
+			}
+			return nil, fmt.Errorf("failed to parse checkpoints line %d: %w", i+1, err)
+		}
+		if record.Fingerprint != "" {
+			s.state.fingerprint = record.Fingerprint
+		}
+		if record.Completed != nil {
+			s.state.markCompleted(record.Completed)
+		}
+	}
+
+	// Unless the file ends with a complete line, rewrite it without the last
+	// one, or with it terminated, so that records can be appended.
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Fingerprint returns the recorded workflow fingerprint, or "" if none has
+// been recorded.
+func (s *FileCheckpointStore) Fingerprint(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.fingerprint, nil
+}
+
+// SetFingerprint records the workflow fingerprint, rewriting the file.
+func (s *FileCheckpointStore) SetFingerprint(_ context.Context, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.fingerprint = fingerprint
+	return s.save()
+}
+
+// Completed reports whether the step at path has completed.
+func (s *FileCheckpointStore) Completed(_ context.Context, path []string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.isCompleted(path), nil
+}
+
+// MarkCompleted records that the step at path has completed, appending a
+// record to the file.
+func (s *FileCheckpointStore) MarkCompleted(_ context.Context, path []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.state.markCompleted(path) {
+		return nil
+	}
+	return s.append(checkpointRecord{Completed: path})
+}
+
+// Invalidate forgets the step at path, all steps within it, and all steps
+// enclosing it, rewriting the file. An empty path forgets every checkpoint
+// and the fingerprint.
+func (s *FileCheckpointStore) Invalidate(_ context.Context, path []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.invalidate(path)
+	return s.save()
+}
+
+// append appends a record to the file. The caller must hold s.mu.
+func (s *FileCheckpointStore) append(record checkpointRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	// #nosec G304 -- the checkpoint file is chosen by the caller
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	return nil
+}
+
+// save rewrites the file with the current state. The caller must hold s.mu.
+func (s *FileCheckpointStore) save() error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	if s.state.fingerprint != "" {
+		if err := encoder.Encode(checkpointRecord{Fingerprint: s.state.fingerprint}); err != nil {
+			return fmt.Errorf("failed to marshal checkpoints: %w", err)
+		}
+	}
+	for _, path := range s.state.completed {
+		if err := encoder.Encode(checkpointRecord{Completed: path}); err != nil {
+			return fmt.Errorf("failed to marshal checkpoints: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	return nil
+}
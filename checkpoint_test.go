@@ -0,0 +1,338 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingStore is a CheckpointStore whose every operation fails.
+type failingStore struct{}
+
+func (failingStore) Fingerprint(context.Context) (string, error)       { return "", error1 }
+func (failingStore) SetFingerprint(context.Context, string) error      { return error1 }
+func (failingStore) Completed(context.Context, []string) (bool, error) { return false, error1 }
+func (failingStore) MarkCompleted(context.Context, []string) error     { return error1 }
+func (failingStore) Invalidate(context.Context, []string) error        { return error1 }
+
+// keyedItem provides its own checkpoint key.
+type keyedItem struct{ id string }
+
+func (k keyedItem) CheckpointKey() string { return "id=" + k.id }
+
+// stringerItem is formatted with its String method.
+type stringerItem struct{ name string }
+
+func (s stringerItem) String() string { return s.name }
+
+// expectCompleted checks whether each path is recorded as completed in store.
+func expectCompleted(t *testing.T, store CheckpointStore, want map[string]bool) {
+	t.Helper()
+	for path, completed := range want {
+		got, err := store.Completed(t.Context(), strings.Split(path, "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != completed {
+			t.Errorf("path %q: got completed %v, want %v", path, got, completed)
+		}
+	}
+}
+
+func TestCheckpointed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ResumesAfterFailure", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		fail := true
+		workflow := Checkpointed(store, Do(
+			Named("a", Increment(1)),
+			Named("b", func(ctx context.Context, c *CountingFlow) error {
+				if fail {
+					return error1
+				}
+				return Increment(10)(ctx, c)
+			}),
+			Named("c", Increment(100)),
+		))
+
+		runStepTest(t, workflow, 1, matches(error1))
+		expectCompleted(t, store, map[string]bool{"a": true, "b": false, "c": false})
+
+		fail = false
+		var c CountingFlow
+		trace, err := Traced(workflow)(t.Context(), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Counter != 110 {
+			t.Errorf("expected only b and c to run, got counter %d", c.Counter)
+		}
+		skipped := trace.FindEvent(NameMatches("a"))
+		if skipped == nil || skipped.SkipReason != SkipReasonCheckpointed {
+			t.Errorf("expected a to be skipped as checkpointed, got %+v", skipped)
+		}
+		if ran := trace.FindEvent(NameMatches("b")); ran == nil || ran.SkipReason != "" {
+			t.Errorf("expected b to run, got %+v", ran)
+		}
+
+		// A third run skips everything.
+		runStepTest(t, workflow, 0, isNil)
+	})
+
+	t.Run("OuterStepSkipsChildren", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		workflow := Checkpointed(store, Named("outer", Do(
+			Named("inner", Increment(1)),
+		)))
+		runStepTest(t, workflow, 1, isNil)
+		expectCompleted(t, store, map[string]bool{"outer": true, "outer/inner": true})
+
+		trace, _ := Traced(workflow)(t.Context(), &CountingFlow{})
+		if len(trace.Events) != 1 || trace.Events[0].SkipReason != SkipReasonCheckpointed {
+			t.Errorf("expected a single skipped event, got %+v", trace.Events)
+		}
+	})
+
+	t.Run("ItemKeys", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		deploy := func(string) Step[*CountingFlow] { return Named("deploy", Increment(1)) }
+		workflow := Checkpointed(store, Named("services", InParallel(
+			ForEach(Value[*CountingFlow]([]string{"api", "web"}), deploy),
+		)))
+		runStepTest(t, workflow, 2, isNil)
+		expectCompleted(t, store, map[string]bool{
+			"services":              true,
+			"services/[api]/deploy": true,
+			"services/[web]/deploy": true,
+		})
+
+		// Invalidating one item reruns it, and only it.
+		if err := store.Invalidate(t.Context(), []string{"services", "[api]"}); err != nil {
+			t.Fatal(err)
+		}
+		expectCompleted(t, store, map[string]bool{
+			"services":              false,
+			"services/[api]/deploy": false,
+			"services/[web]/deploy": true,
+		})
+		runStepTest(t, workflow, 1, isNil)
+	})
+
+	t.Run("ItemKeyFormats", func(t *testing.T) {
+		t.Parallel()
+		testCases := []struct {
+			item any
+			want string
+		}{
+			{item: keyedItem{id: "7"}, want: "[id=7]"},
+			{item: "name", want: "[name]"},
+			{item: stringerItem{name: "str"}, want: "[str]"},
+			{item: 42, want: "[42]"},
+			{item: uint8(3), want: "[3]"},
+			{item: struct{}{}, want: "[#5]"},
+		}
+		for _, tc := range testCases {
+			if got := itemKey(5, tc.item); got != tc.want {
+				t.Errorf("itemKey(%v): got %q, want %q", tc.item, got, tc.want)
+			}
+		}
+	})
+
+	t.Run("ApplyItemKeys", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		save := NamedConsume("save", func(_ context.Context, c *CountingFlow, n int) error {
+			c.Counter += int64(n)
+			return nil
+		})
+		workflow := Checkpointed(store, With(Value[*CountingFlow]([]int{1, 2}), Apply(save)))
+		runStepTest(t, workflow, 3, isNil)
+		expectCompleted(t, store, map[string]bool{"[1]/save": true, "[2]/save": true})
+	})
+
+	t.Run("RepeatedPathsRun", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		workflow := Checkpointed(store, While(
+			Not(CountEquals(3)),
+			Named("poll", Increment(1)),
+		))
+		runStepTest(t, workflow, 3, isNil)
+		// The first iteration is skipped on the rerun, but the loop still
+		// finishes.
+		runStepTest(t, workflow, 3, isNil)
+	})
+
+	t.Run("ExtractsAlwaysRun", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		workflow := Checkpointed(store, With(
+			NamedExtract("count", func(_ context.Context, c *CountingFlow) (int64, error) {
+				c.Counter++
+				return c.Counter, nil
+			}),
+			SendCount,
+		))
+		runStepTest(t, workflow, 1, isNil)
+		runStepTest(t, workflow, 1, isNil)
+	})
+
+	t.Run("Fingerprint", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		step := Named("a", Increment(1))
+		runStepTest(t, Checkpointed(store, step, WithFingerprint("v1")), 1, isNil)
+		runStepTest(t, Checkpointed(store, step, WithFingerprint("v1")), 0, isNil)
+
+		var c CountingFlow
+		err := Checkpointed(store, step, WithFingerprint("v2"))(t.Context(), &c)
+		var mismatch *FingerprintMismatchError
+		if !errors.As(err, &mismatch) || mismatch.Stored != "v1" || mismatch.Current != "v2" {
+			t.Errorf("expected fingerprint mismatch v1/v2, got %v", err)
+		}
+		if c.Counter != 0 {
+			t.Errorf("expected nothing to run, got counter %d", c.Counter)
+		}
+
+		// Invalidating everything starts afresh with the new fingerprint.
+		if err := store.Invalidate(t.Context(), nil); err != nil {
+			t.Fatal(err)
+		}
+		runStepTest(t, Checkpointed(store, step, WithFingerprint("v2")), 1, isNil)
+	})
+
+	t.Run("StoreErrors", func(t *testing.T) {
+		t.Parallel()
+		runStepTest(t, Checkpointed(failingStore{}, Named("a", Increment(1))), 0,
+			all(matches(error1), contains("a: error 1")))
+		runStepTest(t, Checkpointed(failingStore{}, Increment(1), WithFingerprint("v1")), 0,
+			matches(error1))
+	})
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("PersistsAcrossRuns", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "checkpoints.json")
+		workflow := func(store CheckpointStore) Step[*CountingFlow] {
+			return Checkpointed(store, Do(
+				Named("a", Increment(1)),
+				Named("b", Increment(10)),
+			), WithFingerprint("v1"))
+		}
+
+		store, err := NewFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runStepTest(t, workflow(store), 11, isNil)
+
+		reopened, err := NewFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fingerprint, _ := reopened.Fingerprint(t.Context())
+		if fingerprint != "v1" {
+			t.Errorf("got fingerprint %q, want %q", fingerprint, "v1")
+		}
+		expectCompleted(t, reopened, map[string]bool{"a": true, "b": true})
+
+		if err := reopened.Invalidate(t.Context(), []string{"b"}); err != nil {
+			t.Fatal(err)
+		}
+		reopened, err = NewFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runStepTest(t, workflow(reopened), 10, isNil)
+
+		if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+			t.Errorf("expected temporary file to be renamed, got %v", err)
+		}
+	})
+
+	t.Run("InvalidFile", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "checkpoints.json")
+		if err := os.WriteFile(path, []byte("not json\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewFileCheckpointStore(path); err == nil {
+			t.Error("expected error for invalid checkpoint file")
+		}
+	})
+
+	t.Run("TornRecord", func(t *testing.T) {
+		t.Parallel()
+		// A crash while appending leaves the last record cut short.
+		path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+		data := `{"fingerprint":"v1"}` + "\n" + `{"completed":["a"]}` + "\n" + `{"completed":["b`
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		store, err := NewFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCompleted(t, store, map[string]bool{"a": true, "b": false})
+
+		// Later records are appended after the last complete one.
+		if err := store.MarkCompleted(t.Context(), []string{"b"}); err != nil {
+			t.Fatal(err)
+		}
+		reopened, err := NewFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCompleted(t, reopened, map[string]bool{"a": true, "b": true})
+	})
+
+	t.Run("UnterminatedRecord", func(t *testing.T) {
+		t.Parallel()
+		// A crash between writing a record and its newline leaves a complete
+		// record without one.
+		path := filepath.Join(t.TempDir(), "checkpoints.jsonl")
+		if err := os.WriteFile(path, []byte(`{"completed":["a"]}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		store, err := NewFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCompleted(t, store, map[string]bool{"a": true, "b": false})
+
+		// The next record is not appended to the same line.
+		if err := store.MarkCompleted(t.Context(), []string{"b"}); err != nil {
+			t.Fatal(err)
+		}
+		reopened, err := NewFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCompleted(t, reopened, map[string]bool{"a": true, "b": true})
+	})
+
+	t.Run("UnwritableFile", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "missing", "checkpoints.json")
+		store, err := NewFileCheckpointStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.MarkCompleted(t.Context(), []string{"a"})
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected write error, got %v", err)
+		}
+	})
+}
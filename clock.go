@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Clock tells the time and creates timers.
+//
+// All time-dependent combinators ([Sleep], [WithTimeout], [WithDeadline], the
+// backoff predicates, [RetryBudget], the logging decorators, and [Traced])
+// read the clock installed with [WithClock]. By default, they use the system
+// clock.
+//
+// Substituting a fake clock makes timing deterministic in tests; see the
+// flowtest package for one.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer creates a Timer that sends the current time on its channel
+	// after at least duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// A Timer is a single event created by a [Clock].
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing. It returns true if the call stops
+	// the timer, false if the timer has already fired or been stopped.
+	Stop() bool
+}
+
+// SystemClock returns the [Clock] backed by the [time] package.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// systemClock is the default Clock, backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// systemTimer adapts a *time.Timer to the Timer interface.
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// WithClock configures a [Step] to use a specific [Clock] for all timing.
+//
+// This is typically applied once at the root of a workflow, most often in
+// tests. To make [Trace.Start] and [Trace.Duration] use the clock as well as
+// the events themselves, install it outside of [Traced]:
+//
+//	clock := flowtest.NewFakeClock(time.Unix(0, 0))
+//	var trace *flow.Trace
+//	workflow := flow.WithClock(clock,
+//	    flow.Spawn(
+//	        flow.Traced(myWorkflow),
+//	        func(_ context.Context, t *flow.Trace) error {
+//	            trace = t
+//	            return nil
+//	        },
+//	    ),
+//	)
+func WithClock[T any](clock Clock, step Step[T]) Step[T] {
+	return func(ctx context.Context, t T) error {
+		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+		f2 := newFlowCtx(ctx, f)
+		f2.clock = clock
+		return step(f2, t)
+	}
+}
+
+// ClockFrom returns the [Clock] from the context, or [SystemClock] if none is set.
+//
+// This is useful for custom steps that need to measure or wait for time
+// consistently with the rest of the workflow.
+func ClockFrom(ctx context.Context) Clock {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if !ok {
+		return systemClock{}
+	}
+	return f.clock
+}
+
+// WithRand configures a [Step] to use a specific random number generator for
+// backoff jitter.
+//
+// By default, jitter uses the global generator from math/rand/v2. Installing
+// a generator with a fixed seed makes jittered backoff schedules
+// reproducible:
+//
+//	rng := rand.New(rand.NewPCG(1, 2))
+//	workflow := flow.WithRand(rng, myWorkflow)
+//
+// The generator is guarded by a mutex, so it may be shared by parallel steps.
+func WithRand[T any](r *rand.Rand, step Step[T]) Step[T] {
+	return func(ctx context.Context, t T) error {
+		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+		f2 := newFlowCtx(ctx, f)
+		f2.rand = &lockedRand{r: r}
+		return step(f2, t)
+	}
+}
+
+// random is the subset of *rand.Rand used for jitter.
+type random interface {
+	Int64N(n int64) int64
+	Float64() float64
+}
+
+// globalRand is the default random, backed by the math/rand/v2 global generator.
+type globalRand struct{}
+
+func (globalRand) Int64N(n int64) int64 {
+	// #nosec G404 -- see applyJitter for rationale
+	return rand.Int64N(n)
+}
+
+func (globalRand) Float64() float64 {
+	// #nosec G404 -- see applyJitter for rationale
+	return rand.Float64()
+}
+
+// lockedRand makes a *rand.Rand safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Int64N(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int64N(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+// randFrom returns the random number generator from the context.
+func randFrom(ctx context.Context) random {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if !ok {
+		return globalRand{}
+	}
+	return f.rand
+}
+
+// sleep waits for duration d on the context's clock, returning the context's
+// error if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := ClockFrom(ctx).NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withDeadline is like [context.WithDeadlineCause], but measures time with
+// the context's clock. If cause is nil, it defaults to
+// [context.DeadlineExceeded].
+func withDeadline(ctx context.Context, deadline time.Time, cause error) (context.Context, context.CancelFunc) {
+	clock := ClockFrom(ctx)
+	if _, ok := clock.(systemClock); ok {
+		return context.WithDeadlineCause(ctx, deadline, cause)
+	}
+
+	if cause == nil {
+		cause = context.DeadlineExceeded
+	}
+	inner, cancel := context.WithCancelCause(ctx)
+	deadlineCtx := &clockDeadlineCtx{
+		Context:  inner,
+		deadline: deadline,
+		done:     make(chan struct{}),
+	}
+	closeDone := sync.OnceFunc(func() { close(deadlineCtx.done) })
+	context.AfterFunc(inner, closeDone)
+	expire := func() {
+		if inner.Err() == nil {
+			deadlineCtx.expired.Store(true)
+		}
+		cancel(cause)
+		closeDone()
+	}
+	stop := func() {
+		cancel(context.Canceled)
+		closeDone()
+	}
+	wait := deadline.Sub(clock.Now())
+	if wait <= 0 {
+		expire()
+		return deadlineCtx, stop
+	}
+	timer := clock.NewTimer(wait)
+	go func() {
+		select {
+		case <-timer.C():
+			expire()
+		case <-inner.Done():
+			timer.Stop()
+		}
+	}()
+	return deadlineCtx, stop
+}
+
+// withTimeout is like [context.WithTimeoutCause], but measures time with the
+// context's clock.
+func withTimeout(ctx context.Context, timeout time.Duration, cause error) (context.Context, context.CancelFunc) {
+	return withDeadline(ctx, ClockFrom(ctx).Now().Add(timeout), cause)
+}
+
+// clockDeadlineCtx is a context cancelled by a deadline on a non-system Clock.
+//
+// The embedded context is cancelled with the deadline's cause when the
+// deadline passes; clockDeadlineCtx then reports [context.DeadlineExceeded]
+// from Err so that it behaves like a context created by
+// [context.WithDeadline]. It has its own Done channel so that contexts derived
+// from it take their error from Err rather than from the embedded context.
+type clockDeadlineCtx struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	// expired reports whether the deadline passed before the context was
+	// otherwise cancelled.
+	expired atomic.Bool
+}
+
+func (c *clockDeadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockDeadlineCtx) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *clockDeadlineCtx) Err() error {
+	select {
+	case <-c.done:
+	default:
+		return nil
+	}
+	if c.expired.Load() {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
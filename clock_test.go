@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock is a minimal Clock whose timers fire only when fired explicitly.
+// See the flowtest package for a complete fake clock.
+type manualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []chan time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) NewTimer(time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, ch)
+	return manualTimer(ch)
+}
+
+// fireAll fires every timer created so far.
+func (c *manualClock) fireAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.timers {
+		select {
+		case ch <- c.now:
+		default:
+		}
+	}
+}
+
+type manualTimer chan time.Time
+
+func (t manualTimer) C() <-chan time.Time { return t }
+func (t manualTimer) Stop() bool          { return true }
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("DefaultsToSystemClock", func(t *testing.T) {
+		t.Parallel()
+		if _, ok := ClockFrom(t.Context()).(systemClock); !ok {
+			t.Errorf("expected system clock, got %T", ClockFrom(t.Context()))
+		}
+		if SystemClock() != ClockFrom(t.Context()) {
+			t.Error("expected SystemClock to match the default clock")
+		}
+	})
+
+	t.Run("WithClock", func(t *testing.T) {
+		t.Parallel()
+		clock := &manualClock{now: time.Unix(100, 0)}
+		var got Clock
+		err := WithClock(clock, Named("step", func(ctx context.Context, _ *CountingFlow) error {
+			got = ClockFrom(ctx)
+			return nil
+		}))(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != clock {
+			t.Errorf("expected installed clock, got %T", got)
+		}
+	})
+
+	t.Run("DeadlineOnCustomClock", func(t *testing.T) {
+		t.Parallel()
+		clock := &manualClock{now: time.Unix(100, 0)}
+		deadline := time.Unix(160, 0)
+		var ctxDeadline time.Time
+		step := WithDeadline(deadline, func(ctx context.Context, _ *CountingFlow) error {
+			ctxDeadline, _ = ctx.Deadline()
+			clock.fireAll()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		err := WithClock(clock, step)(t.Context(), &CountingFlow{})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if !ctxDeadline.Equal(deadline) {
+			t.Errorf("got deadline %v, want %v", ctxDeadline, deadline)
+		}
+	})
+
+	t.Run("PastDeadlineOnCustomClock", func(t *testing.T) {
+		t.Parallel()
+		clock := &manualClock{now: time.Unix(100, 0)}
+		step := WithDeadline(time.Unix(50, 0), func(ctx context.Context, _ *CountingFlow) error {
+			return ctx.Err()
+		})
+		err := WithClock(clock, step)(t.Context(), &CountingFlow{})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+	})
+
+	t.Run("ParentCancellationOnCustomClock", func(t *testing.T) {
+		t.Parallel()
+		clock := &manualClock{now: time.Unix(100, 0)}
+		ctx, cancel := context.WithCancel(t.Context())
+		step := WithTimeout(time.Minute, func(ctx context.Context, _ *CountingFlow) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		err := WithClock(clock, step)(ctx, &CountingFlow{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected cancellation, got %v", err)
+		}
+	})
+}
+
+func TestWithRand(t *testing.T) {
+	t.Parallel()
+
+	// jitterFor computes a sequence of jittered delays with the given seed.
+	jitterFor := func(seed uint64) []time.Duration {
+		var delays []time.Duration
+		step := WithRand(rand.New(rand.NewPCG(seed, seed)), func(ctx context.Context, _ *CountingFlow) error {
+			cfg := backoffConfig{fullJitter: true}
+			for range 5 {
+				delays = append(delays, cfg.finalDelay(ctx, time.Second, nil))
+			}
+			return nil
+		})
+		_ = step(t.Context(), &CountingFlow{})
+		return delays
+	}
+
+	first, second := jitterFor(7), jitterFor(7)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("delay %d: got %v and %v for the same seed", i, first[i], second[i])
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Command flowtrace inspects trace files written by flow, either as a JSON
+// array by Trace.WriteTo or as JSON Lines by WithStreamTo.
+//
+// Usage:
+//
+//	flowtrace <command> [flags] [file...]
+//
+// The commands are:
+//
+//	tree      show the steps as a tree
+//	flat      show the steps one per line, with full paths
+//	summary   show statistics for each step
+//	errors    show the steps that failed
+//	slow      show the steps that took at least --min
+//	filter    show the steps matching --path, --name, --error, and --status
+//	diff      compare two traces
+//	tail      show the last steps to finish, and with -f, follow new ones
+//
+// Files are read from standard input if none, or "-", is given, and several
+// files are merged into one trace. Run "flowtrace <command> -h" for the flags
+// of a command.
+//
+// flowtrace exits with status 1 if the steps it shows include final failures,
+// that is steps that failed, timed out, panicked, or were cancelled, but not
+// attempts that were retried (for diff, if the second trace has new errors),
+// and with status 2 if the command is invalid or a trace cannot be read, so
+// it can be used in scripts:
+//
+//	if ! flowtrace errors trace.jsonl; then
+//	    echo "the deployment failed" >&2
+//	fi
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sam-fredrickson/flow"
+)
+
+// Exit statuses.
+const (
+	exitOK     = 0
+	exitErrors = 1
+	exitUsage  = 2
+)
+
+// errUsage reports invalid flags, whose message the flag package has already
+// printed.
+var errUsage = errors.New("invalid usage")
+
+// command is a flowtrace subcommand. run reports whether the steps it showed
+// include errors.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) (bool, error)
+}
+
+var commands = []command{
+	{"tree", "show the steps as a tree", runTree},
+	{"flat", "show the steps one per line, with full paths", runFlat},
+	{"summary", "show statistics for each step", runSummary},
+	{"errors", "show the steps that failed", runErrors},
+	{"slow", "show the steps that took at least --min", runSlow},
+	{"filter", "show the steps matching --path, --name, --error, and --status", runFilter},
+	{"diff", "compare two traces", runDiff},
+	{"tail", "show the last steps to finish, and with -f, follow new ones", runTail},
+}
+
+// env holds a command's standard streams.
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command named by the first argument and returns the exit
+// status.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		e.usage()
+		return exitUsage
+	}
+	if name := args[0]; name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		e.usage()
+		return exitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		found, err := cmd.run(ctx, e, args[1:])
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.Is(err, errUsage):
+			return exitUsage
+		case err != nil:
+			fmt.Fprintf(stderr, "flowtrace %s: %v\n", cmd.name, err)
+			return exitUsage
+		case found:
+			return exitErrors
+		}
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "flowtrace: unknown command %q\n", args[0])
+	e.usage()
+	return exitUsage
+}
+
+// usage prints the list of commands.
+func (e *env) usage() {
+	fmt.Fprintln(e.stderr, "Usage: flowtrace <command> [flags] [file...]")
+	fmt.Fprintln(e.stderr)
+	fmt.Fprintln(e.stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(e.stderr, "  %-8s  %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(e.stderr)
+	fmt.Fprintln(e.stderr, `Run "flowtrace <command> -h" for the flags of a command.`)
+}
+
+// flags returns the flag set of a command taking the given arguments.
+func (e *env) flags(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: flowtrace %s [flags] %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses a command's flags.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// readTraces reads and merges the traces in the given files, or standard
+// input if there are none.
+func (e *env) readTraces(paths []string) (*flow.Trace, error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	traces := make([]*flow.Trace, 0, len(paths))
+	for _, path := range paths {
+		trace, err := e.readTrace(path)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, trace)
+	}
+	if len(traces) == 1 {
+		return traces[0], nil
+	}
+	return flow.MergeTraces(traces...), nil
+}
+
+// readTrace reads the trace in a file, or standard input for "-".
+func (e *env) readTrace(path string) (*flow.Trace, error) {
+	if path == "-" {
+		return flow.ReadTrace(e.stdin)
+	}
+	// #nosec G304 -- the trace file is chosen by the user
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	trace, err := flow.ReadTrace(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return trace, nil
+}
+
+// output holds the flags choosing how steps are shown.
+type output struct {
+	flat     *bool
+	json     *bool
+	critical *bool
+}
+
+// outputFlags adds the flags choosing how steps are shown.
+func outputFlags(fs *flag.FlagSet) output {
+	return output{
+		flat: fs.Bool("flat", false, "show one step per line, with full paths"),
+		json: fs.Bool("json", false, "show the steps as a JSON array"),
+	}
+}
+
+// write shows the steps of a trace, and reports whether any failed.
+func (e *env) write(trace *flow.Trace, out output) (bool, error) {
+	var err error
+	switch {
+	case *out.json:
+		_, err = trace.WriteTo(e.stdout)
+	case *out.flat:
+		_, err = trace.WriteFlatText(e.stdout)
+	default:
+		critical := out.critical != nil && *out.critical
+		_, err = trace.WriteTextWith(e.stdout, flow.TextOptions{CriticalPath: critical})
+	}
+	return anyFailed(trace.Events), err
+}
+
+// anyFailed reports whether any of the events is a final failure of a step.
+func anyFailed(events []flow.TraceEvent) bool {
+	return slices.ContainsFunc(events, isFailed)
+}
+
+// isFailed reports whether the event is a final failure of a step. Retried
+// attempts, and events that are not steps, have other statuses.
+func isFailed(event flow.TraceEvent) bool {
+	switch event.Status {
+	case flow.StatusFailed, flow.StatusTimedOut, flow.StatusPanicked, flow.StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// show reads the traces named by the remaining arguments, filters them, and
+// shows the matching steps.
+func (e *env) show(fs *flag.FlagSet, out output, filters ...flow.TraceFilter) (bool, error) {
+	trace, err := e.readTraces(fs.Args())
+	if err != nil {
+		return false, err
+	}
+	if len(filters) > 0 {
+		trace = trace.Filter(filters...)
+	}
+	return e.write(trace, out)
+}
+
+func runTree(_ context.Context, e *env, args []string) (bool, error) {
+	fs := e.flags("tree", "[file...]")
+	out := outputFlags(fs)
+	out.critical = fs.Bool("critical", false, "mark the critical path and show the slack of other steps")
+	if err := parse(fs, args); err != nil {
+		return false, err
+	}
+	return e.show(fs, out)
+}
+
+func runFlat(_ context.Context, e *env, args []string) (bool, error) {
+	fs := e.flags("flat", "[file...]")
+	out := outputFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return false, err
+	}
+	*out.flat = true
+	return e.show(fs, out)
+}
+
+func runSummary(_ context.Context, e *env, args []string) (bool, error) {
+	fs := e.flags("summary", "[file...]")
+	by := fs.String("by", "path", `group steps by "path" or "name"`)
+	ignoreIndices := fs.Bool("ignore-indices", false, `group steps differing only in index numbers, such as "item-1" and "item-2"`)
+	asJSON := fs.Bool("json", false, "show the summary as JSON")
+	if err := parse(fs, args); err != nil {
+		return false, err
+	}
+	groupBy, err := groupBy(*by, *ignoreIndices)
+	if err != nil {
+		return false, err
+	}
+	trace, err := e.readTraces(fs.Args())
+	if err != nil {
+		return false, err
+	}
+
+	summary := trace.Summarize(groupBy)
+	if *asJSON {
+		_, err = summary.WriteTo(e.stdout)
+	} else {
+		_, err = summary.WriteText(e.stdout)
+	}
+	return anyFailed(trace.Events), err
+}
+
+// groupBy returns the grouping named by the --by flag.
+func groupBy(by string, ignoreIndices bool) (flow.GroupBy, error) {
+	var groupBy flow.GroupBy
+	switch by {
+	case "path":
+		groupBy = flow.ByPath()
+	case "name":
+		groupBy = flow.ByName()
+	default:
+		return nil, fmt.Errorf(`invalid --by %q, want "path" or "name"`, by)
+	}
+	if ignoreIndices {
+		groupBy = flow.IgnoringIndices(groupBy)
+	}
+	return groupBy, nil
+}
+
+func runErrors(_ context.Context, e *env, args []string) (bool, error) {
+	fs := e.flags("errors", "[file...]")
+	out := outputFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return false, err
+	}
+	return e.show(fs, out, flow.HasError())
+}
+
+func runSlow(_ context.Context, e *env, args []string) (bool, error) {
+	fs := e.flags("slow", "[file...]")
+	out := outputFlags(fs)
+	minDuration := fs.Duration("min", time.Second, "show steps that took at least this long")
+	if err := parse(fs, args); err != nil {
+		return false, err
+	}
+	return e.show(fs, out, flow.MinDuration(*minDuration))
+}
+
+func runFilter(_ context.Context, e *env, args []string) (bool, error) {
+	fs := e.flags("filter", "[file...]")
+	out := outputFlags(fs)
+	path := fs.String("path", "", `show steps whose dotted path matches this glob, such as "db.*"`)
+	name := fs.String("name", "", "show steps whose name matches this glob")
+	errPattern := fs.String("error", "", `show steps whose error matches this glob, such as "*timeout*"`)
+	status := fs.String("status", "", `show steps with one of these comma-separated statuses, such as "failed,timed_out"`)
+	minDuration := fs.Duration("min", 0, "show steps that took at least this long")
+	maxDuration := fs.Duration("max", 0, "show steps that took at most this long")
+	if err := parse(fs, args); err != nil {
+		return false, err
+	}
+
+	var filters []flow.TraceFilter
+	for _, glob := range []struct {
+		flag, pattern string
+		filter        func(string) flow.TraceFilter
+	}{
+		{"path", *path, flow.PathMatches},
+		{"name", *name, flow.NameMatches},
+		{"error", *errPattern, flow.ErrorMatches},
+	} {
+		if glob.pattern == "" {
+			continue
+		}
+		// The filters match nothing for a malformed pattern; report it instead.
+		if _, err := filepath.Match(glob.pattern, ""); err != nil {
+			return false, fmt.Errorf("invalid --%s %q: %w", glob.flag, glob.pattern, err)
+		}
+		filters = append(filters, glob.filter(glob.pattern))
+	}
+	if *status != "" {
+		var statuses []flow.EventStatus
+		for _, s := range strings.Split(*status, ",") {
+			statuses = append(statuses, flow.EventStatus(strings.TrimSpace(s)))
+		}
+		filters = append(filters, flow.HasStatus(statuses...))
+	}
+	if *minDuration > 0 {
+		filters = append(filters, flow.MinDuration(*minDuration))
+	}
+	if *maxDuration > 0 {
+		filters = append(filters, flow.MaxDuration(*maxDuration))
+	}
+	return e.show(fs, out, filters...)
+}
+
+func runDiff(_ context.Context, e *env, args []string) (bool, error) {
+	fs := e.flags("diff", "<before> <after>")
+	by := fs.String("by", "path", `match steps by "path" or "name"`)
+	ignoreIndices := fs.Bool("ignore-indices", false, `match steps differing only in index numbers, such as "item-1" and "item-2"`)
+	minChange := fs.Duration("min-change", 0, "show duration changes of at least this much")
+	minRatio := fs.Float64("min-ratio", 0, "show duration changes of at least this fraction, such as 0.2 for 20%")
+	asJSON := fs.Bool("json", false, "show the diff as JSON")
+	if err := parse(fs, args); err != nil {
+		return false, err
+	}
+	if fs.NArg() != 2 {
+		return false, fmt.Errorf("expected two trace files, got %d", fs.NArg())
+	}
+	groupBy, err := groupBy(*by, *ignoreIndices)
+	if err != nil {
+		return false, err
+	}
+	before, err := e.readTrace(fs.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	after, err := e.readTrace(fs.Arg(1))
+	if err != nil {
+		return false, err
+	}
+
+	diff := flow.DiffTraces(before, after, flow.DiffOptions{
+		GroupBy:           groupBy,
+		MinChange:         *minChange,
+		MinRelativeChange: *minRatio,
+	})
+	if *asJSON {
+		_, err = diff.WriteTo(e.stdout)
+	} else {
+		_, err = diff.WriteText(e.stdout)
+	}
+	return len(diff.NewErrors) > 0, err
+}
@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sam-fredrickson/flow"
+)
+
+var errTimeout = errors.New("query timeout")
+
+// writeTrace streams the trace of a workflow to a file and returns its path.
+// The workflow's db.query step fails if fail is true.
+func writeTrace(t *testing.T, name string, fail bool) string {
+	t.Helper()
+	query := func(context.Context, *struct{}) error {
+		if fail {
+			return errTimeout
+		}
+		return nil
+	}
+	return streamTrace(t, name, flow.Named("query", query))
+}
+
+// writeRetriedTrace is like writeTrace, but the workflow's db.query step
+// fails once and succeeds when retried.
+func writeRetriedTrace(t *testing.T, name string) string {
+	t.Helper()
+	failed := false
+	query := func(context.Context, *struct{}) error {
+		if !failed {
+			failed = true
+			return errTimeout
+		}
+		return nil
+	}
+	return streamTrace(t, name, flow.Retry(flow.Named("query", query), flow.UpTo(2)))
+}
+
+// streamTrace streams the trace of a db workflow ending with query to a
+// file and returns its path.
+func streamTrace(t *testing.T, name string, query flow.Step[*struct{}]) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	noop := func(context.Context, *struct{}) error { return nil }
+	_, _ = flow.Traced(flow.Named("db", flow.Do(
+		flow.Named("connect", noop),
+		query,
+	)), flow.WithStreamTo(f))(t.Context(), &struct{}{})
+	return path
+}
+
+// runCommand runs flowtrace with the given arguments.
+func runCommand(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(t.Context(), args, strings.NewReader(""), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestCommands(t *testing.T) {
+	t.Parallel()
+	ok := writeTrace(t, "ok.jsonl", false)
+	failed := writeTrace(t, "failed.jsonl", true)
+	retried := writeRetriedTrace(t, "retried.jsonl")
+
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		code    int
+		want    []string
+		notWant []string
+	}{
+		{"Tree", []string{"tree", ok}, exitOK, []string{"db (", "  connect ("}, nil},
+		{"TreeErrors", []string{"tree", failed}, exitErrors, []string{"  query (", "[ERROR: query timeout]"}, nil},
+		{"TreeCritical", []string{"tree", "--critical", ok}, exitOK, []string{"[CRITICAL]"}, nil},
+		{"Flat", []string{"flat", ok}, exitOK, []string{"db > connect ("}, nil},
+		{"JSON", []string{"flat", "--json", ok}, exitOK, []string{`"step_names": [`}, nil},
+		{"Summary", []string{"summary", "--by", "name", ok}, exitOK, []string{"STEP", "connect "}, []string{"db.connect"}},
+		{"Errors", []string{"errors", failed}, exitErrors, []string{"query"}, []string{"connect"}},
+		{"NoErrors", []string{"errors", ok}, exitOK, nil, []string{"db"}},
+		{"Slow", []string{"slow", "--min", "1h", failed}, exitOK, nil, []string{"db"}},
+		{"FilterPath", []string{"filter", "--path", "db.*", ok}, exitOK, []string{"connect", "query"}, []string{"db ("}},
+		{"FilterError", []string{"filter", "--error", "*timeout*", "--flat", failed}, exitErrors, []string{"db > query ("}, []string{"connect"}},
+		{"FilterStatus", []string{"filter", "--status", "succeeded", failed}, exitOK, []string{"connect"}, []string{"query"}},
+		{"Merge", []string{"flat", ok, failed}, exitErrors, []string{"[ERROR: query timeout]"}, nil},
+		{"DiffNewErrors", []string{"diff", ok, failed}, exitErrors, []string{"new errors:", "! db.query: query timeout"}, nil},
+		{"DiffResolved", []string{"diff", failed, ok}, exitOK, []string{"resolved errors:"}, nil},
+		{"Tail", []string{"tail", "-n", "1", failed}, exitErrors, []string{"db ("}, []string{"connect"}},
+		{"Retried", []string{"tree", retried}, exitOK, []string{"[RETRIED: query timeout]"}, nil},
+		{"RetriedSummary", []string{"summary", retried}, exitOK, []string{"db.query"}, nil},
+		{"RetriedTail", []string{"tail", retried}, exitOK, []string{"[RETRIED: query timeout]"}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			stdout, stderr, code := runCommand(t, tc.args...)
+			if code != tc.code {
+				t.Errorf("got exit status %d, want %d; stderr:\n%s", code, tc.code, stderr)
+			}
+			for _, s := range tc.want {
+				if !strings.Contains(stdout, s) {
+					t.Errorf("expected %q in output:\n%s", s, stdout)
+				}
+			}
+			for _, s := range tc.notWant {
+				if strings.Contains(stdout, s) {
+					t.Errorf("expected no %q in output:\n%s", s, stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+	ok := writeTrace(t, "ok.jsonl", false)
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"NoCommand", nil, exitUsage, "Commands:"},
+		{"Help", []string{"help"}, exitOK, "Commands:"},
+		{"UnknownCommand", []string{"show", ok}, exitUsage, `unknown command "show"`},
+		{"CommandHelp", []string{"slow", "-h"}, exitOK, "-min duration"},
+		{"UnknownFlag", []string{"tree", "--depth", "2", ok}, exitUsage, "flag provided but not defined"},
+		{"MissingFile", []string{"tree", filepath.Join(t.TempDir(), "missing.jsonl")}, exitUsage, "no such file"},
+		{"BadPattern", []string{"filter", "--path", "[", ok}, exitUsage, "invalid --path"},
+		{"BadGroup", []string{"summary", "--by", "size", ok}, exitUsage, "invalid --by"},
+		{"DiffArgs", []string{"diff", ok}, exitUsage, "expected two trace files"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, stderr, code := runCommand(t, tc.args...)
+			if code != tc.code {
+				t.Errorf("got exit status %d, want %d", code, tc.code)
+			}
+			if !strings.Contains(stderr, tc.want) {
+				t.Errorf("expected %q in stderr:\n%s", tc.want, stderr)
+			}
+		})
+	}
+}
+
+func TestStdin(t *testing.T) {
+	t.Parallel()
+	data, err := os.ReadFile(writeTrace(t, "failed.jsonl", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	code := run(t.Context(), []string{"errors", "--flat"}, bytes.NewReader(data), &stdout, &bytes.Buffer{})
+	if code != exitErrors || !strings.Contains(stdout.String(), "db > query (") {
+		t.Errorf("got exit status %d and output:\n%s", code, stdout.String())
+	}
+}
+
+func TestTailUnterminated(t *testing.T) {
+	t.Parallel()
+	data, err := os.ReadFile(writeTrace(t, "failed.jsonl", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last step to finish, db, is on the last line.
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	var stdout bytes.Buffer
+	code := run(t.Context(), []string{"tail", "-n", "1"}, bytes.NewReader(data), &stdout, &bytes.Buffer{})
+	if code != exitErrors || !strings.HasPrefix(stdout.String(), "db (") {
+		t.Errorf("got exit status %d and output:\n%s", code, stdout.String())
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTailFollow(t *testing.T) {
+	// Not parallel: the test shortens tailInterval.
+	tailInterval = time.Millisecond
+	t.Cleanup(func() { tailInterval = 250 * time.Millisecond })
+
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(`{"id":1,"step_names":["first"],"duration":1000}` + "\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	var stdout syncBuffer
+	done := make(chan int)
+	go func() {
+		done <- run(ctx, []string{"tail", "-f", path}, nil, &stdout, &bytes.Buffer{})
+	}()
+
+	waitFor := func(s string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(stdout.String(), s) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %q in output:\n%s", s, stdout.String())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor("first (1µs)\n")
+
+	// A line written in two parts is shown once complete.
+	if _, err := f.WriteString(`{"id":2,"step_names":["sec`); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := f.WriteString(`ond"],"duration":2000,"error":"boom","status":"failed"}` + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("second (2µs) [ERROR: boom]\n")
+
+	cancel()
+	if code := <-done; code != exitErrors {
+		t.Errorf("got exit status %d, want %d", code, exitErrors)
+	}
+}
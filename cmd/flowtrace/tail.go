@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sam-fredrickson/flow"
+)
+
+// tailInterval is how often tail -f checks for new steps.
+var tailInterval = 250 * time.Millisecond
+
+func runTail(ctx context.Context, e *env, args []string) (bool, error) {
+	fs := e.flags("tail", "[file]")
+	n := fs.Int("n", 10, "show this many of the last steps to finish")
+	follow := fs.Bool("f", false, "keep showing steps as they are streamed to the file")
+	if err := parse(fs, args); err != nil {
+		return false, err
+	}
+	if fs.NArg() > 1 {
+		return false, fmt.Errorf("expected at most one trace file, got %d", fs.NArg())
+	}
+
+	r := e.stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		// #nosec G304 -- the trace file is chosen by the user
+		f, err := os.Open(path)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		r = f
+	}
+	lines := &lineReader{r: bufio.NewReader(r), follow: *follow}
+
+	// Show the last n steps streamed so far, in the order they finished.
+	var last []flow.TraceEvent
+	for {
+		event, ok, err := lines.next()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			break
+		}
+		last = append(last, event)
+		if len(last) > *n {
+			last = last[1:]
+		}
+	}
+	if err := e.writeEvents(last...); err != nil {
+		return false, err
+	}
+	failed := anyFailed(last)
+	if !*follow {
+		return failed, nil
+	}
+
+	// Follow the steps streamed from now on, until interrupted.
+	for {
+		event, ok, err := lines.next()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return failed, nil
+			case <-time.After(tailInterval):
+				continue
+			}
+		}
+		failed = failed || isFailed(event)
+		if err := e.writeEvents(event); err != nil {
+			return false, err
+		}
+	}
+}
+
+// writeEvents shows steps one per line, with full paths.
+func (e *env) writeEvents(events ...flow.TraceEvent) error {
+	_, err := (&flow.Trace{Events: events}).WriteFlatText(e.stdout)
+	return err
+}
+
+// lineReader decodes trace events streamed as JSON Lines from a file that
+// may still be growing.
+type lineReader struct {
+	r       *bufio.Reader
+	partial []byte
+
+	// follow is whether more lines may be written after the end of the input.
+	follow bool
+}
+
+// next returns the next event, or false if the input holds no complete line
+// yet. When following, a partial line is kept until the rest of it is
+// written; otherwise the input is finished, and its last line is decoded even
+// without a trailing newline.
+func (l *lineReader) next() (flow.TraceEvent, bool, error) {
+	for {
+		data, err := l.r.ReadBytes('\n')
+		l.partial = append(l.partial, data...)
+		if errors.Is(err, io.EOF) {
+			if l.follow || len(bytes.TrimSpace(l.partial)) == 0 {
+				return flow.TraceEvent{}, false, nil
+			}
+		} else if err != nil {
+			return flow.TraceEvent{}, false, fmt.Errorf("failed to read trace: %w", err)
+		}
+
+		line := bytes.TrimSpace(l.partial)
+		l.partial = nil
+		if len(line) == 0 {
+			continue
+		}
+		var event flow.TraceEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return flow.TraceEvent{}, false, fmt.Errorf("failed to decode trace: %w", err)
+		}
+		return event, true, nil
+	}
+}
@@ -89,12 +89,16 @@ func Map[T any, A any](
 	f func(A) Step[T],
 ) Transform[T, []A, []Step[T]] {
 	return func(ctx context.Context, t T, as []A) ([]Step[T], error) {
+		tracked := tracksPath(ctx)
 		steps := make([]Step[T], len(as))
 		for i, a := range as {
-			steps[i] = f(a)
-			if steps[i] == nil {
+			step := f(a)
+			if step == nil {
 				return nil, &IndexedError{Index: i, Err: ErrNilStep}
 			}
+			steps[i] = func(ctx context.Context, t T) error {
+				return step(elementCtx(ctx, tracked, i, a), t)
+			}
 		}
 		return steps, nil
 	}
@@ -156,7 +160,7 @@ func Collect[T, U any](f Extract[T, U]) Extract[T, []U] {
 				return nil, err
 			}
 
-			item, err := f(ctx, t)
+			item, err := f(withIndex(ctx, i), t)
 			if err != nil {
 				if errors.Is(err, ErrExhausted) {
 					return collected, nil
@@ -195,6 +199,7 @@ func Collect[T, U any](f Extract[T, U]) Extract[T, []U] {
 func Render[T, In, Out any](f Transform[T, In, Out]) Transform[T, []In, []Out] {
 	return func(ctx context.Context, t T, items []In) ([]Out, error) {
 		results := make([]Out, len(items))
+		tracked := tracksPath(ctx)
 
 		for i, item := range items {
 			// Check for cancellation
@@ -202,7 +207,7 @@ func Render[T, In, Out any](f Transform[T, In, Out]) Transform[T, []In, []Out] {
 				return nil, err
 			}
 
-			out, err := f(ctx, t, item)
+			out, err := f(elementCtx(ctx, tracked, i, item), t, item)
 			if err != nil {
 				return nil, &IndexedError{Index: i, Err: err}
 			}
@@ -243,13 +248,14 @@ func Render[T, In, Out any](f Transform[T, In, Out]) Transform[T, []In, []Out] {
 //	)
 func Apply[T, U any](f Consume[T, U]) Consume[T, []U] {
 	return func(ctx context.Context, t T, items []U) error {
+		tracked := tracksPath(ctx)
 		for i, item := range items {
 			// Check for cancellation
 			if err := ctx.Err(); err != nil {
 				return err
 			}
 
-			if err := f(ctx, t, item); err != nil {
+			if err := f(elementCtx(ctx, tracked, i, item), t, item); err != nil {
 				return &IndexedError{Index: i, Err: err}
 			}
 		}
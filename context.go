@@ -6,6 +6,7 @@ import (
 	"context"
 	"log"
 	"log/slog"
+	"slices"
 	"time"
 )
 
@@ -39,6 +40,66 @@ type flowCtx struct {
 	// slogger is the active slog.Logger for structured logging.
 	// Defaults to slog.Default() if not explicitly set.
 	slogger *slog.Logger
+
+	// retryBudget limits retries across the workflow.
+	// nil if no budget is installed.
+	retryBudget *RetryBudget
+
+	// clock is used for all timing in the workflow.
+	// Defaults to the system clock if not explicitly set.
+	clock Clock
+
+	// rand is used for backoff jitter.
+	// Defaults to the math/rand/v2 global generator if not explicitly set.
+	rand random
+
+	// index is the index of the collection element being processed.
+	// -1 if no collection element is being processed.
+	index int
+
+	// attempt is the 1-based attempt number of the innermost Retry.
+	// 0 if not within a Retry.
+	attempt int
+
+	// retryAttempt collects the trace events of the current attempt of the
+	// innermost Retry. nil if not within a Retry or not tracing.
+	retryAttempt *retryAttempt
+
+	// retryDecision is the decision whether to retry after a failed attempt
+	// of the innermost Retry, for its predicates. nil outside of them.
+	retryDecision *retryDecision
+
+	// recoverPanics reports whether panics in steps are converted to
+	// RecoveredPanic errors. Set by the RecoverPanics run options.
+	recoverPanics bool
+
+	// drain signals the workflow to stop starting new steps.
+	// nil if no drain is installed.
+	drain *Drain
+
+	// controllers can pause the workflow, innermost last.
+	// nil if the workflow is not controllable.
+	controllers []*Controller
+
+	// event is the trace event of the innermost Named step.
+	// -1 if there is none.
+	event eventIdx
+
+	// checkpoints records completed steps for Checkpointed.
+	// nil if checkpointing is not enabled.
+	checkpoints *checkpointer
+
+	// journal records and replays step results for Journaled.
+	// nil if journaling is not enabled.
+	journal *journalRun
+
+	// stepPath is the path of the innermost Named step or collection
+	// element: step names interleaved with element keys. Only maintained
+	// while checkpointing or journaling is enabled.
+	stepPath []string
+
+	// dryRun reports whether side effects are skipped. Set by DryRun.
+	dryRun bool
 }
 
 // Value implements context.Context.Value by intercepting flowCtxKey lookups
@@ -62,22 +123,67 @@ func (f *flowCtx) Value(key any) any {
 //   - names: nil (no name stack)
 //   - logger: log.Default()
 //   - slogger: slog.Default()
+//   - retryBudget: nil (unlimited retries)
+//   - clock: the system clock
+//   - rand: the math/rand/v2 global generator
+//   - index: -1 (no collection element)
+//   - attempt: 0 (not retrying)
+//   - retryAttempt: nil
+//   - retryDecision: nil
+//   - recoverPanics: false
+//   - drain: nil (never drains)
+//   - controllers: nil (never paused)
+//   - event: -1 (no enclosing trace event)
+//   - checkpoints: nil (no checkpointing)
+//   - journal: nil (no journaling)
+//   - stepPath: nil
+//   - dryRun: false
 func newFlowCtx(parent context.Context, origin *flowCtx) *flowCtx {
 	if origin == nil {
 		origin = &flowCtx{
-			Context: parent,
-			trace:   nil,
-			names:   nil,
-			logger:  log.Default(),
-			slogger: slog.Default(),
+			Context:       parent,
+			trace:         nil,
+			names:         nil,
+			logger:        log.Default(),
+			slogger:       slog.Default(),
+			retryBudget:   nil,
+			clock:         systemClock{},
+			rand:          globalRand{},
+			index:         -1,
+			attempt:       0,
+			retryAttempt:  nil,
+			retryDecision: nil,
+			recoverPanics: false,
+			drain:         nil,
+			controllers:   nil,
+			event:         noEvent,
+			checkpoints:   nil,
+			journal:       nil,
+			stepPath:      nil,
+			dryRun:        false,
 		}
 	}
 	f := &flowCtx{
-		Context: parent,
-		trace:   origin.trace,
-		names:   origin.names,
-		logger:  origin.logger,
-		slogger: origin.slogger,
+		Context:       parent,
+		trace:         origin.trace,
+		names:         origin.names,
+		logger:        origin.logger,
+		slogger:       origin.slogger,
+		retryBudget:   origin.retryBudget,
+		clock:         origin.clock,
+		rand:          origin.rand,
+		index:         origin.index,
+		attempt:       origin.attempt,
+		retryAttempt:  origin.retryAttempt,
+		retryDecision: origin.retryDecision,
+		recoverPanics: origin.recoverPanics,
+		drain:         origin.drain,
+		controllers:   origin.controllers,
+		event:         origin.event,
+		checkpoints:   origin.checkpoints,
+		journal:       origin.journal,
+		stepPath:      origin.stepPath,
+		dryRun:        origin.dryRun,
 	}
 	return f
 }
@@ -85,25 +191,31 @@ func newFlowCtx(parent context.Context, origin *flowCtx) *flowCtx {
 // WithTimeout wraps a step with a timeout.
 //
 // The step is executed with a derived context that will be cancelled after
-// the specified duration. If the step does not complete within the timeout,
-// it will receive a cancelled context and should return [context.DeadlineExceeded].
+// the specified duration, as measured by the workflow's [Clock]. If the step
+// does not complete within the timeout, it will receive a cancelled context
+// and should return [context.DeadlineExceeded].
 //
 // Example:
 //
 //	flow.WithTimeout(5*time.Second, ExpensiveOperation())
 func WithTimeout[T any](timeout time.Duration, step Step[T]) Step[T] {
 	return func(ctx context.Context, t T) error {
-		ctx, cancel := context.WithTimeout(ctx, timeout)
+		cause := &DeadlineError{Path: Names(ctx), Timeout: timeout}
+		ctx, cancel := withTimeout(ctx, timeout, cause)
 		defer cancel()
-		return step(ctx, t)
+		return withCause(ctx, step(ctx, t), cause)
 	}
 }
 
 // WithDeadline wraps a step with an absolute deadline.
 //
 // The step is executed with a derived context that will be cancelled at the
-// specified time. If the step does not complete before the deadline, it will
-// receive a cancelled context and should return [context.DeadlineExceeded].
+// specified time, as measured by the workflow's [Clock]. If the step does not
+// complete before the deadline, it will receive a cancelled context and should
+// return [context.DeadlineExceeded].
+//
+// As with [WithTimeout], the cancellation cause is a *[DeadlineError], which
+// is returned in place of the context's bare error.
 //
 // Example:
 //
@@ -111,16 +223,29 @@ func WithTimeout[T any](timeout time.Duration, step Step[T]) Step[T] {
 //	flow.WithDeadline(deadline, ExpensiveOperation())
 func WithDeadline[T any](deadline time.Time, step Step[T]) Step[T] {
 	return func(ctx context.Context, t T) error {
-		ctx, cancel := context.WithDeadline(ctx, deadline)
+		cause := &DeadlineError{Path: Names(ctx), Deadline: deadline}
+		ctx, cancel := withDeadline(ctx, deadline, cause)
 		defer cancel()
-		return step(ctx, t)
+		return withCause(ctx, step(ctx, t), cause)
 	}
 }
 
+// withCause returns cause in place of err if err is exactly the context's
+// bare error and ctx was cancelled with that cause, so that callers learn why
+// the context was done. Other errors, including those that wrap the context's
+// error, are returned unchanged.
+func withCause(ctx context.Context, err error, cause error) error {
+	if err != nil && err == ctx.Err() && context.Cause(ctx) == cause {
+		return cause
+	}
+	return err
+}
+
 // Sleep pauses execution for the specified duration.
 //
 // The sleep respects context cancellation, returning [context.Canceled] if
-// the context is cancelled before the duration elapses.
+// the context is cancelled before the duration elapses. The duration is
+// measured by the workflow's [Clock].
 //
 // This is useful in polling loops or when adding delays between operations.
 //
@@ -135,14 +260,92 @@ func WithDeadline[T any](deadline time.Time, step Step[T]) Step[T] {
 //	)
 func Sleep[T any](duration time.Duration) Step[T] {
 	return func(ctx context.Context, t T) error {
-		timer := time.NewTimer(duration)
-		defer timer.Stop()
-
-		select {
-		case <-timer.C:
-			return nil
-		case <-ctx.Done():
-			return ctx.Err()
-		}
+		return sleep(ctx, duration)
+	}
+}
+
+// withIndex returns a context recording that the collection element at index
+// is being processed.
+func withIndex(ctx context.Context, index int) context.Context {
+	f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+	f2 := newFlowCtx(ctx, f)
+	f2.index = index
+	return f2
+}
+
+// withItem is like withIndex, but also records the element's key in the
+// step path if checkpointing or journaling is enabled.
+func withItem(ctx context.Context, index int, item any) context.Context {
+	f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+	f2 := newFlowCtx(ctx, f)
+	f2.index = index
+	if f2.tracksPath() {
+		f2.stepPath = append(slices.Clone(f2.stepPath), itemKey(index, item))
 	}
+	return f2
+}
+
+// elementCtx returns the context for processing the collection element at
+// index: that of withItem if the step path is tracked, or else that of
+// withIndex, which saves converting and formatting the element's key.
+func elementCtx[E any](ctx context.Context, tracked bool, index int, item E) context.Context {
+	if tracked {
+		return withItem(ctx, index, item)
+	}
+	return withIndex(ctx, index)
+}
+
+// tracksPath reports whether ctx maintains the step path, as it does within
+// a Checkpointed or Journaled workflow.
+func tracksPath(ctx context.Context) bool {
+	f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+	return f != nil && f.tracksPath()
+}
+
+// withAttempt returns a context recording the 1-based attempt number of the
+// innermost Retry, and collecting the attempt's trace events in retry.
+func withAttempt(ctx context.Context, attempt int, retry *retryAttempt) context.Context {
+	f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+	f2 := newFlowCtx(ctx, f)
+	f2.attempt = attempt
+	f2.retryAttempt = retry
+	return f2
+}
+
+// withRetryDecision returns a context in which the predicates of a Retry
+// decide whether to retry.
+func withRetryDecision(ctx context.Context, decision *retryDecision) context.Context {
+	f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+	f2 := newFlowCtx(ctx, f)
+	f2.retryDecision = decision
+	return f2
+}
+
+// withEvent returns a context whose innermost trace event is idx.
+func withEvent(ctx context.Context, idx eventIdx) context.Context {
+	f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+	f2 := newFlowCtx(ctx, f)
+	f2.event = idx
+	return f2
+}
+
+// withRecoverPanics returns a context in which steps convert panics to
+// RecoveredPanic errors.
+func withRecoverPanics(ctx context.Context) context.Context {
+	f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+	f2 := newFlowCtx(ctx, f)
+	f2.recoverPanics = true
+	return f2
+}
+
+// recoversPanics reports whether steps run with ctx should convert panics to
+// RecoveredPanic errors.
+func recoversPanics(ctx context.Context) bool {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	return ok && f.recoverPanics
+}
+
+// tracksPath reports whether the step path is maintained.
+func (f *flowCtx) tracksPath() bool {
+	return f.checkpoints != nil || f.journal != nil
 }
@@ -3,8 +3,11 @@
 package flow
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -177,3 +180,143 @@ func TestSleep(t *testing.T) {
 }
 
 // ==== Test Fixtures ====
+
+func TestCancellationCauses(t *testing.T) {
+	t.Parallel()
+
+	// waitForCancel blocks until its context is done and returns the bare error.
+	waitForCancel := func(ctx context.Context, _ *CountingFlow) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.Run("WithTimeout", func(t *testing.T) {
+		t.Parallel()
+		err := Named("deploy", Named("apply",
+			WithTimeout(10*time.Millisecond, waitForCancel),
+		))(t.Context(), &CountingFlow{})
+		var deadlineErr *DeadlineError
+		if !errors.As(err, &deadlineErr) {
+			t.Fatalf("expected DeadlineError, got %v", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error to match context.DeadlineExceeded, got %v", err)
+		}
+		if deadlineErr.Timeout != 10*time.Millisecond {
+			t.Errorf("got timeout %v, want 10ms", deadlineErr.Timeout)
+		}
+		want := `timeout of 10ms exceeded at "deploy > apply"`
+		if deadlineErr.Error() != want {
+			t.Errorf("got message %q, want %q", deadlineErr.Error(), want)
+		}
+	})
+
+	t.Run("WithDeadline", func(t *testing.T) {
+		t.Parallel()
+		deadline := time.Now().Add(10 * time.Millisecond)
+		err := WithDeadline(deadline, waitForCancel)(t.Context(), &CountingFlow{})
+		var deadlineErr *DeadlineError
+		if !errors.As(err, &deadlineErr) {
+			t.Fatalf("expected DeadlineError, got %v", err)
+		}
+		if !deadlineErr.Deadline.Equal(deadline) {
+			t.Errorf("got deadline %v, want %v", deadlineErr.Deadline, deadline)
+		}
+		want := "deadline " + deadline.Format(time.RFC3339) + " exceeded"
+		if err.Error() != want {
+			t.Errorf("got message %q, want %q", err.Error(), want)
+		}
+	})
+
+	t.Run("WrappedErrorUnchanged", func(t *testing.T) {
+		t.Parallel()
+		wrapping := func(ctx context.Context, c *CountingFlow) error {
+			return fmt.Errorf("waiting: %w", waitForCancel(ctx, c))
+		}
+		err := WithTimeout(10*time.Millisecond, wrapping)(t.Context(), &CountingFlow{})
+		if err.Error() != "waiting: context deadline exceeded" {
+			t.Errorf("unexpected error %q", err.Error())
+		}
+	})
+
+	t.Run("ParentCancellationUnchanged", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		err := WithTimeout(time.Hour, waitForCancel)(ctx, &CountingFlow{})
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("InParallelSiblings", func(t *testing.T) {
+		t.Parallel()
+		var cause error
+		sibling := func(ctx context.Context, c *CountingFlow) error {
+			err := waitForCancel(ctx, c)
+			cause = context.Cause(ctx)
+			return err
+		}
+		err := InParallel(Steps(
+			sibling,
+			Named("broken", IncrementAndFail(error1)),
+		))(t.Context(), &CountingFlow{})
+		if !errors.Is(err, error1) {
+			t.Errorf("expected error1, got %v", err)
+		}
+		if got := FailedPath(cause); len(got) != 1 || got[0] != "broken" {
+			t.Errorf("expected cause from step broken, got %v", cause)
+		}
+	})
+
+	t.Run("PerAttemptTimeout", func(t *testing.T) {
+		t.Parallel()
+		err := Named("call", RetryWith(
+			RetryOptions{PerAttemptTimeout: 5 * time.Millisecond},
+			waitForCancel,
+			UpTo(2),
+		))(t.Context(), &CountingFlow{})
+		var deadlineErr *DeadlineError
+		if !errors.As(err, &deadlineErr) {
+			t.Fatalf("expected DeadlineError, got %v", err)
+		}
+		if !IsTransient(err) {
+			t.Errorf("expected timed out attempt to be transient, got %v", err)
+		}
+	})
+
+	t.Run("Traced", func(t *testing.T) {
+		t.Parallel()
+		trace, _ := Traced(Do(
+			InParallel(Steps(
+				Named("broken", IncrementAndFail(error1)),
+				Named("sibling", waitForCancel),
+			)),
+		))(t.Context(), &CountingFlow{})
+		sibling := trace.FindEvent(NameMatches("sibling"))
+		if sibling == nil || sibling.Cause != "broken: error 1" {
+			t.Errorf("expected sibling cause %q, got %+v", "broken: error 1", sibling)
+		}
+		broken := trace.FindEvent(NameMatches("broken"))
+		if broken == nil || broken.Cause != "" {
+			t.Errorf("expected no cause for failing step, got %+v", broken)
+		}
+
+		trace, _ = Traced(Named("outer",
+			WithTimeout(5*time.Millisecond, Named("inner", waitForCancel)),
+		))(t.Context(), &CountingFlow{})
+		inner := trace.FindEvent(NameMatches("inner"))
+		want := `timeout of 5ms exceeded at "outer"`
+		if inner == nil || inner.Cause != want {
+			t.Errorf("expected inner cause %q, got %+v", want, inner)
+		}
+
+		var buf bytes.Buffer
+		if _, err := trace.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "[CAUSE: "+want+"]") {
+			t.Errorf("expected cause in text output, got:\n%s", buf.String())
+		}
+	})
+}
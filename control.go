@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"sync"
+)
+
+// ControllerState is the state of a [Controller].
+type ControllerState int
+
+const (
+	// ControllerRunning means steps are started as usual.
+	ControllerRunning ControllerState = iota
+
+	// ControllerPaused means no new steps are started until the controller
+	// is resumed. Steps that were already running when the controller was
+	// paused continue until they reach their next boundary.
+	ControllerPaused
+)
+
+func (s ControllerState) String() string {
+	switch s {
+	case ControllerRunning:
+		return "running"
+	case ControllerPaused:
+		return "paused"
+	default:
+		return "unknown"
+	}
+}
+
+// A Controller pauses and resumes a running workflow between steps.
+//
+// Create one with [Controllable]. While paused, every combinator boundary
+// blocks before starting its next step: [Do] and [InSerial] between steps,
+// [InParallel] before dispatching each step, [While] before each iteration,
+// and [Retry] before each retry. Steps already running are not interrupted.
+//
+// A blocked boundary returns early if the context is cancelled, or if the
+// workflow starts draining (see [Drain]).
+//
+// When tracing is enabled (via [Traced]), the time spent blocked is recorded
+// in [TraceEvent.Paused] for every enclosing step.
+//
+// A Controller is safe for concurrent use.
+type Controller struct {
+	mu      sync.Mutex
+	resumed chan struct{} // nil while running; closed on Resume
+}
+
+// Controllable wraps a [Step] so that it can be paused and resumed with the
+// returned [Controller].
+//
+// Example:
+//
+//	rollout, ctrl := flow.Controllable(Rollout())
+//	http.HandleFunc("/pause", func(http.ResponseWriter, *http.Request) { ctrl.Pause() })
+//	http.HandleFunc("/resume", func(http.ResponseWriter, *http.Request) { ctrl.Resume() })
+//	err := rollout(ctx, state)
+func Controllable[T any](step Step[T]) (Step[T], *Controller) {
+	c := &Controller{}
+	return func(ctx context.Context, t T) error {
+		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+		f2 := newFlowCtx(ctx, f)
+		f2.controllers = append(append([]*Controller{}, f2.controllers...), c)
+		return step(f2, t)
+	}, c
+}
+
+// Pause stops the workflow from starting new steps until [Controller.Resume]
+// is called. Pausing a paused controller has no effect.
+func (c *Controller) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed == nil {
+		c.resumed = make(chan struct{})
+	}
+}
+
+// Resume unblocks a paused workflow. Resuming a running controller has no
+// effect.
+func (c *Controller) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+// State returns the controller's current state.
+func (c *Controller) State() ControllerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed != nil {
+		return ControllerPaused
+	}
+	return ControllerRunning
+}
+
+// wait blocks while the controller is paused, reporting whether it blocked
+// at all. It returns early with the context's error if ctx is done, or with
+// nil if the workflow starts draining.
+func (c *Controller) wait(ctx context.Context, drain *Drain) (bool, error) {
+	blocked := false
+	for {
+		c.mu.Lock()
+		resumed := c.resumed
+		c.mu.Unlock()
+		if resumed == nil {
+			return blocked, nil
+		}
+		blocked = true
+
+		var draining <-chan struct{}
+		if drain != nil {
+			draining = drain.started
+		}
+		select {
+		case <-resumed:
+			// Check again, in case the controller was paused again.
+		case <-draining:
+			return blocked, nil
+		case <-ctx.Done():
+			return blocked, ctx.Err()
+		}
+	}
+}
+
+// waitWhilePaused blocks while any controller of the workflow is paused,
+// recording the time spent blocked in the trace.
+func waitWhilePaused(ctx context.Context) error {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if !ok || len(f.controllers) == 0 {
+		return nil
+	}
+
+	start := f.clock.Now()
+	blocked := false
+	var err error
+	for _, c := range f.controllers {
+		var b bool
+		b, err = c.wait(ctx, f.drain)
+		blocked = blocked || b
+		if err != nil {
+			break
+		}
+	}
+	if blocked && f.trace != nil {
+		f.trace.recordPause(f.event, f.clock.Now().Sub(start))
+	}
+	return err
+}
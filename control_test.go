@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pauseWith returns a step that pauses the controller and signals that it
+// has done so. The controller is looked up when the step runs, since
+// Controllable returns it only after the step has been built.
+func pauseWith(ctrl **Controller, paused chan<- struct{}) Step[*CountingFlow] {
+	return func(_ context.Context, c *CountingFlow) error {
+		(*ctrl).Pause()
+		atomic.AddInt64(&c.Counter, 1)
+		close(paused)
+		return nil
+	}
+}
+
+// runAsync runs step in a goroutine, returning a channel for its result.
+func runAsync(ctx context.Context, step Step[*CountingFlow], c *CountingFlow) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- step(ctx, c)
+	}()
+	return done
+}
+
+// expectBlocked checks that done has not produced a result after a short wait.
+func expectBlocked(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("expected workflow to be blocked, but it returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestController(t *testing.T) {
+	t.Parallel()
+
+	t.Run("State", func(t *testing.T) {
+		t.Parallel()
+		_, ctrl := Controllable(Increment(1))
+		if ctrl.State() != ControllerRunning || ctrl.State().String() != "running" {
+			t.Errorf("expected running, got %v", ctrl.State())
+		}
+		ctrl.Pause()
+		ctrl.Pause()
+		if ctrl.State() != ControllerPaused || ctrl.State().String() != "paused" {
+			t.Errorf("expected paused, got %v", ctrl.State())
+		}
+		ctrl.Resume()
+		ctrl.Resume()
+		if ctrl.State() != ControllerRunning {
+			t.Errorf("expected running, got %v", ctrl.State())
+		}
+	})
+
+	boundaries := []struct {
+		name string
+		step func(pause Step[*CountingFlow]) Step[*CountingFlow]
+	}{
+		{
+			name: "Do",
+			step: func(pause Step[*CountingFlow]) Step[*CountingFlow] {
+				return Do(pause, Increment(1))
+			},
+		},
+		{
+			name: "InSerial",
+			step: func(pause Step[*CountingFlow]) Step[*CountingFlow] {
+				return InSerial(Steps(pause), Steps(Increment(1)))
+			},
+		},
+		{
+			name: "InParallel",
+			step: func(pause Step[*CountingFlow]) Step[*CountingFlow] {
+				return InParallelWith(ParallelOptions{Limit: 1}, Steps(pause, Increment(1)))
+			},
+		},
+		{
+			name: "While",
+			step: func(pause Step[*CountingFlow]) Step[*CountingFlow] {
+				var first atomic.Bool
+				return While(Not(CountEquals(2)), func(ctx context.Context, c *CountingFlow) error {
+					if first.CompareAndSwap(false, true) {
+						return pause(ctx, c)
+					}
+					return Increment(1)(ctx, c)
+				})
+			},
+		},
+		{
+			name: "Retry",
+			step: func(pause Step[*CountingFlow]) Step[*CountingFlow] {
+				var first atomic.Bool
+				return Retry(func(ctx context.Context, c *CountingFlow) error {
+					if first.CompareAndSwap(false, true) {
+						_ = pause(ctx, c)
+						return error1
+					}
+					return Increment(1)(ctx, c)
+				}, UpTo(2))
+			},
+		},
+	}
+
+	for _, tc := range boundaries {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var ctrl *Controller
+			paused := make(chan struct{})
+			step, c := Controllable(tc.step(pauseWith(&ctrl, paused)))
+			ctrl = c
+
+			var state CountingFlow
+			done := runAsync(t.Context(), step, &state)
+			<-paused
+			expectBlocked(t, done)
+			if got := atomic.LoadInt64(&state.Counter); got != 1 {
+				t.Errorf("expected no steps to start while paused, counter = %d", got)
+			}
+
+			ctrl.Resume()
+			if err := <-done; err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if state.Counter != 2 {
+				t.Errorf("got counter %d, want 2", state.Counter)
+			}
+		})
+	}
+
+	t.Run("PausedBeforeStart", func(t *testing.T) {
+		t.Parallel()
+		step, ctrl := Controllable(InParallel(Steps(Increment(1), Increment(2))))
+		ctrl.Pause()
+		var state CountingFlow
+		done := runAsync(t.Context(), step, &state)
+		expectBlocked(t, done)
+		ctrl.Resume()
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if state.Counter != 3 {
+			t.Errorf("got counter %d, want 3", state.Counter)
+		}
+	})
+
+	t.Run("CancelledWhilePaused", func(t *testing.T) {
+		t.Parallel()
+		var ctrl *Controller
+		paused := make(chan struct{})
+		step, c := Controllable(Do(pauseWith(&ctrl, paused), Increment(1)))
+		ctrl = c
+
+		ctx, cancel := context.WithCancel(t.Context())
+		var state CountingFlow
+		done := runAsync(ctx, step, &state)
+		<-paused
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if state.Counter != 1 {
+			t.Errorf("got counter %d, want 1", state.Counter)
+		}
+	})
+
+	t.Run("DrainedWhilePaused", func(t *testing.T) {
+		t.Parallel()
+		var ctrl *Controller
+		paused := make(chan struct{})
+		step, c := Controllable(Do(pauseWith(&ctrl, paused), Increment(1)))
+		ctrl = c
+
+		drain := NewDrain()
+		var state CountingFlow
+		done := runAsync(t.Context(), WithDrain(drain, step), &state)
+		<-paused
+		drain.Start(0)
+		if err := <-done; !errors.Is(err, ErrDraining) {
+			t.Errorf("expected ErrDraining, got %v", err)
+		}
+		if ctrl.State() != ControllerPaused {
+			t.Errorf("expected controller to remain paused, got %v", ctrl.State())
+		}
+	})
+
+	t.Run("NestedControllers", func(t *testing.T) {
+		t.Parallel()
+		inner, _ := Controllable(Do(Increment(1), Increment(1)))
+		outer, ctrl := Controllable(inner)
+		ctrl.Pause()
+		var state CountingFlow
+		done := runAsync(t.Context(), outer, &state)
+		expectBlocked(t, done)
+		ctrl.Resume()
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Traced", func(t *testing.T) {
+		t.Parallel()
+		var ctrl *Controller
+		paused := make(chan struct{})
+		step, c := Controllable(Named("outer", Do(
+			Named("first", pauseWith(&ctrl, paused)),
+			Named("second", Increment(1)),
+		)))
+		ctrl = c
+
+		result := make(chan *Trace, 1)
+		go func() {
+			trace, _ := Traced(step)(t.Context(), &CountingFlow{})
+			result <- trace
+		}()
+		<-paused
+		time.Sleep(30 * time.Millisecond)
+		ctrl.Resume()
+		trace := <-result
+
+		outer := trace.FindEvent(NameMatches("outer"))
+		if outer.Paused < 10*time.Millisecond || outer.Paused > outer.Duration {
+			t.Errorf("expected outer paused between 10ms and %v, got %v", outer.Duration, outer.Paused)
+		}
+		for _, name := range []string{"first", "second"} {
+			if event := trace.FindEvent(NameMatches(name)); event.Paused != 0 {
+				t.Errorf("expected %s not to be paused, got %v", name, event.Paused)
+			}
+		}
+	})
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDraining is returned by combinators that refuse to start a new step
+// because the workflow's [Drain] has been started.
+var ErrDraining = errors.New("workflow is draining")
+
+// ErrDrainTimeout is the cancellation cause (see [context.Cause]) used when a
+// drain's grace period expires before the in-flight steps have finished.
+var ErrDrainTimeout = errors.New("drain grace period expired")
+
+// A Drain signals a workflow to finish the steps it has started without
+// starting any new ones.
+//
+// Install a Drain with [WithDrain] and call [Drain.Start], typically from a
+// signal handler, to begin draining. From then on, [Do], [InSerial],
+// [InParallel], [While], and [Retry] (and their variants) refuse to start new
+// steps or expand new step providers such as [ForEach], returning
+// [ErrDraining] instead. Steps that are already running keep their context
+// until they finish, unless the grace period passed to Start expires first.
+//
+// Example:
+//
+//	drain := flow.NewDrain()
+//	go func() {
+//	    <-sigterm
+//	    drain.Start(5 * time.Minute)
+//	}()
+//	err := flow.WithDrain(drain, rollout)(ctx, state)
+//	if errors.Is(err, flow.ErrDraining) {
+//	    // Stopped cleanly between steps.
+//	}
+//
+// A Drain is safe for concurrent use and may be shared by several workflows.
+type Drain struct {
+	once    sync.Once
+	started chan struct{}
+	grace   time.Duration
+}
+
+// NewDrain creates a [Drain] that has not been started.
+func NewDrain() *Drain {
+	return &Drain{started: make(chan struct{})}
+}
+
+// Start begins draining.
+//
+// If grace is positive, workflows that are still running when it expires
+// (as measured by each workflow's [Clock]) are cancelled with
+// [ErrDrainTimeout] as the cause. Otherwise, in-flight steps may run to
+// completion however long they take.
+//
+// Only the first call has any effect.
+func (d *Drain) Start(grace time.Duration) {
+	d.once.Do(func() {
+		d.grace = grace
+		close(d.started)
+	})
+}
+
+// Draining reports whether [Drain.Start] has been called.
+func (d *Drain) Draining() bool {
+	select {
+	case <-d.started:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithDrain configures a [Step] to stop starting new steps once drain is
+// started. See [Drain] for details.
+func WithDrain[T any](drain *Drain, step Step[T]) Step[T] {
+	return func(ctx context.Context, t T) error {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+		f2 := newFlowCtx(ctx, f)
+		f2.drain = drain
+
+		go drain.escalate(f2, cancel)
+		return step(f2, t)
+	}
+}
+
+// IsDraining reports whether the workflow's [Drain] has been started.
+//
+// This is useful for custom steps that loop internally and should stop
+// early when the workflow is draining.
+func IsDraining(ctx context.Context) bool {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	return ok && f.drain != nil && f.drain.Draining()
+}
+
+// escalate cancels ctx once the drain's grace period expires, returning early
+// if ctx is done first.
+func (d *Drain) escalate(ctx context.Context, cancel context.CancelCauseFunc) {
+	select {
+	case <-d.started:
+	case <-ctx.Done():
+		return
+	}
+	if d.grace <= 0 {
+		return
+	}
+
+	timer := ClockFrom(ctx).NewTimer(d.grace)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		cancel(ErrDrainTimeout)
+	case <-ctx.Done():
+	}
+}
+
+// checkBoundary is called by combinators before they start a new step. It
+// blocks while the workflow is paused (see [Controller]), then returns
+// [ErrDraining] if the workflow is draining, or the context's error if it was
+// cancelled while paused.
+func checkBoundary(ctx context.Context) error {
+	if err := waitWhilePaused(ctx); err != nil {
+		return err
+	}
+	if IsDraining(ctx) {
+		return ErrDraining
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startDrain returns a step that starts drain while running, then checks that
+// its own context is still live and increments the counter.
+func startDrain(drain *Drain) Step[*CountingFlow] {
+	return func(ctx context.Context, c *CountingFlow) error {
+		drain.Start(0)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		atomic.AddInt64(&c.Counter, 1)
+		return nil
+	}
+}
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		step      func(drain *Drain) Step[*CountingFlow]
+		expected  int64
+		validator func(error) error
+	}{
+		{
+			name: "NotDraining",
+			step: func(_ *Drain) Step[*CountingFlow] {
+				return Do(Increment(1), Increment(2))
+			},
+			expected:  3,
+			validator: isNil,
+		},
+		{
+			name: "Do",
+			step: func(drain *Drain) Step[*CountingFlow] {
+				return Do(startDrain(drain), Increment(1), Increment(1))
+			},
+			expected:  1,
+			validator: matches(ErrDraining),
+		},
+		{
+			name: "DoJoinErrors",
+			step: func(drain *Drain) Step[*CountingFlow] {
+				return DoWith(
+					Options{JoinErrors: true},
+					IncrementAndFail(error1),
+					startDrain(drain),
+					Increment(1),
+				)
+			},
+			expected:  2,
+			validator: all(matches(error1), matches(ErrDraining)),
+		},
+		{
+			name: "InSerialSkipsExpansion",
+			step: func(drain *Drain) Step[*CountingFlow] {
+				return InSerial(
+					Steps(startDrain(drain)),
+					ForEach(
+						func(context.Context, *CountingFlow) ([]int, error) {
+							t.Error("provider expanded while draining")
+							return nil, nil
+						},
+						func(int) Step[*CountingFlow] { return Increment(1) },
+					),
+				)
+			},
+			expected:  1,
+			validator: matches(ErrDraining),
+		},
+		{
+			name: "InParallel",
+			step: func(drain *Drain) Step[*CountingFlow] {
+				return InParallelWith(
+					ParallelOptions{Limit: 1},
+					Steps(startDrain(drain), Increment(1), Increment(1)),
+				)
+			},
+			expected:  1,
+			validator: all(matches(ErrDraining), isNotNil),
+		},
+		{
+			name: "InParallelJoinErrors",
+			step: func(drain *Drain) Step[*CountingFlow] {
+				return InParallelWith(
+					ParallelOptions{Limit: 1, JoinErrors: true},
+					Steps(IncrementAndFail(error1), startDrain(drain), Increment(1)),
+				)
+			},
+			expected:  2,
+			validator: all(matches(error1), matches(ErrDraining)),
+		},
+		{
+			name: "While",
+			step: func(drain *Drain) Step[*CountingFlow] {
+				return While(CountGreaterThan(-1), startDrain(drain))
+			},
+			expected:  1,
+			validator: matches(ErrDraining),
+		},
+		{
+			name: "Retry",
+			step: func(drain *Drain) Step[*CountingFlow] {
+				return Retry(func(ctx context.Context, c *CountingFlow) error {
+					drain.Start(0)
+					return IncrementAndFail(error1)(ctx, c)
+				}, UpTo(5))
+			},
+			expected:  1,
+			validator: all(matches(ErrDraining), matches(error1)),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			drain := NewDrain()
+			runStepTest(t, WithDrain(drain, tc.step(drain)), tc.expected, tc.validator)
+		})
+	}
+
+	t.Run("RunningStepsFinish", func(t *testing.T) {
+		t.Parallel()
+		drain := NewDrain()
+		running := make(chan struct{})
+		inFlight := func(ctx context.Context, c *CountingFlow) error {
+			close(running)
+			for !drain.Draining() {
+				time.Sleep(time.Millisecond)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			atomic.AddInt64(&c.Counter, 1)
+			return nil
+		}
+		trigger := func(_ context.Context, c *CountingFlow) error {
+			<-running
+			drain.Start(0)
+			return nil
+		}
+		step := WithDrain(drain, InParallel(Steps(inFlight, trigger)))
+		runStepTest(t, step, 1, isNil)
+	})
+
+	t.Run("GraceEscalates", func(t *testing.T) {
+		t.Parallel()
+		drain := NewDrain()
+		var cause error
+		stuck := func(ctx context.Context, _ *CountingFlow) error {
+			drain.Start(10 * time.Millisecond)
+			<-ctx.Done()
+			cause = context.Cause(ctx)
+			return ctx.Err()
+		}
+		err := WithDrain(drain, stuck)(t.Context(), &CountingFlow{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if !errors.Is(cause, ErrDrainTimeout) {
+			t.Errorf("expected ErrDrainTimeout cause, got %v", cause)
+		}
+	})
+
+	t.Run("IsDraining", func(t *testing.T) {
+		t.Parallel()
+		if IsDraining(t.Context()) {
+			t.Error("expected no drain in plain context")
+		}
+		drain := NewDrain()
+		drain.Start(0)
+		drain.Start(time.Nanosecond) // only the first call has any effect
+		err := WithDrain(drain, func(ctx context.Context, _ *CountingFlow) error {
+			if !IsDraining(ctx) {
+				t.Error("expected IsDraining to report true")
+			}
+			time.Sleep(5 * time.Millisecond)
+			return ctx.Err()
+		})(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Errorf("expected no cancellation without grace period, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+)
+
+// SkipReasonDryRun is the [TraceEvent.SkipReason] recorded for side effects
+// that would have run if the workflow were not in dry-run mode.
+const SkipReasonDryRun = "would run"
+
+// DryRun runs a workflow in dry-run mode: steps marked with [SideEffect],
+// [SideEffectConsume], or [SideEffectExtract] are skipped, and, if tracing is
+// enabled, recorded in the trace with [TraceEvent.SkipReason] set to
+// [SkipReasonDryRun]. The trace of a dry run therefore shows what the
+// workflow would do.
+//
+// All other steps run as usual. In particular, extracts run, so that dynamic
+// expansion with [ForEach] and the other collection helpers reflects real
+// data. Custom steps can check [IsDryRun] to avoid side effects themselves.
+//
+// Within [Checkpointed] and [Journaled] workflows, a dry run may skip
+// completed steps and replay recorded results, but records nothing.
+//
+// Example:
+//
+//	trace, err := flow.Traced(flow.DryRun(SetupEnvironment()))(ctx, state)
+//	if err != nil {
+//	    return err
+//	}
+//	plan := trace.Filter(flow.Skipped(flow.SkipReasonDryRun))
+//	plan.WriteText(os.Stdout)
+func DryRun[T any](step Step[T]) Step[T] {
+	return func(ctx context.Context, t T) error {
+		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+		f2 := newFlowCtx(ctx, f)
+		f2.dryRun = true
+		return step(f2, t)
+	}
+}
+
+// IsDryRun reports whether the workflow is running in dry-run mode (see
+// [DryRun]).
+func IsDryRun(ctx context.Context) bool {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	return ok && f.dryRun
+}
+
+// SideEffect wraps a [Step] with a name, marking it as a side effect that is
+// skipped in dry-run mode (see [DryRun]).
+//
+// Outside dry-run mode, SideEffect behaves exactly like [Named].
+func SideEffect[T any](name string, step Step[T]) Step[T] {
+	named := Named(name, step)
+	return func(ctx context.Context, t T) error {
+		if IsDryRun(ctx) {
+			skipDryRun(ctx, name)
+			return nil
+		}
+		return named(ctx, t)
+	}
+}
+
+// SideEffectConsume wraps a [Consume] with a name, marking it as a side
+// effect that is skipped in dry-run mode (see [DryRun]).
+//
+// Outside dry-run mode, SideEffectConsume behaves exactly like
+// [NamedConsume].
+func SideEffectConsume[T any, U any](name string, consume Consume[T, U]) Consume[T, U] {
+	named := NamedConsume(name, consume)
+	return func(ctx context.Context, t T, u U) error {
+		if IsDryRun(ctx) {
+			skipDryRun(ctx, name)
+			return nil
+		}
+		return named(ctx, t, u)
+	}
+}
+
+// SideEffectExtract wraps an [Extract] with a name, opting it out of running
+// in dry-run mode (see [DryRun]). This is useful for extracts that have side
+// effects of their own, such as creating a resource and returning its ID.
+//
+// In dry-run mode the extract is skipped and returns placeholder instead.
+// Outside dry-run mode, SideEffectExtract behaves exactly like
+// [NamedExtract].
+func SideEffectExtract[T any, U any](name string, extract Extract[T, U], placeholder U) Extract[T, U] {
+	named := NamedExtract(name, extract)
+	return func(ctx context.Context, t T) (U, error) {
+		if IsDryRun(ctx) {
+			skipDryRun(ctx, name)
+			return placeholder, nil
+		}
+		return named(ctx, t)
+	}
+}
+
+// skipDryRun records a side effect skipped in dry-run mode in the trace.
+func skipDryRun(ctx context.Context, name string) {
+	if trace := getTrace(ctx); trace != nil {
+		_, names := addName(ctx, name)
+		trace.recordSkip(ctx, names, SkipReasonDryRun)
+	}
+}
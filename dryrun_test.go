@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	// deploy is a workflow that lists services with an extract, then deploys
+	// each of them as a side effect.
+	deploy := func(deployed *[]string) Step[*CountingFlow] {
+		list := NamedExtract("list", func(_ context.Context, c *CountingFlow) ([]string, error) {
+			c.Counter++
+			return []string{"api", "web"}, nil
+		})
+		return Named("deploy", InSerial(ForEach(list, func(name string) Step[*CountingFlow] {
+			return SideEffect(name, func(context.Context, *CountingFlow) error {
+				*deployed = append(*deployed, name)
+				return nil
+			})
+		})))
+	}
+
+	t.Run("SkipsSideEffects", func(t *testing.T) {
+		t.Parallel()
+		var deployed []string
+		var c CountingFlow
+		trace, err := Traced(DryRun(deploy(&deployed)))(t.Context(), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deployed) != 0 {
+			t.Errorf("expected no side effects, got %v", deployed)
+		}
+		if c.Counter != 1 {
+			t.Errorf("expected the extract to run, got counter %d", c.Counter)
+		}
+
+		plan := trace.Filter(Skipped(SkipReasonDryRun))
+		if len(plan.Events) != 2 {
+			t.Fatalf("expected 2 would-run events, got %+v", plan.Events)
+		}
+		if got := strings.Join(plan.Events[0].Names, "/"); got != "deploy/api" {
+			t.Errorf("expected deploy/api, got %s", got)
+		}
+		var buf bytes.Buffer
+		if _, err := trace.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "[SKIPPED: would run]") {
+			t.Errorf("expected would-run steps in text output, got:\n%s", buf.String())
+		}
+	})
+
+	t.Run("LiveRun", func(t *testing.T) {
+		t.Parallel()
+		var deployed []string
+		runStepTest(t, deploy(&deployed), 1, isNil)
+		if strings.Join(deployed, ",") != "api,web" {
+			t.Errorf("expected side effects to run, got %v", deployed)
+		}
+	})
+
+	t.Run("IsDryRun", func(t *testing.T) {
+		t.Parallel()
+		var got []bool
+		check := func(ctx context.Context, _ *CountingFlow) error {
+			got = append(got, IsDryRun(ctx))
+			return nil
+		}
+		runStepTest(t, Do(check, DryRun(Named("inner", check))), 0, isNil)
+		if len(got) != 2 || got[0] || !got[1] {
+			t.Errorf("expected [false true], got %v", got)
+		}
+		if IsDryRun(t.Context()) {
+			t.Error("expected a plain context not to be in dry-run mode")
+		}
+	})
+
+	t.Run("Variants", func(t *testing.T) {
+		t.Parallel()
+		create := SideEffectExtract("create", func(_ context.Context, c *CountingFlow) (int64, error) {
+			c.Counter++
+			return 42, nil
+		}, -1)
+		var saved []int64
+		save := SideEffectConsume("save", func(_ context.Context, c *CountingFlow, id int64) error {
+			c.Counter++
+			saved = append(saved, id)
+			return nil
+		})
+		other := func(_ context.Context, _ *CountingFlow, id int64) error {
+			saved = append(saved, id)
+			return nil
+		}
+
+		runStepTest(t, DryRun(Do(With(create, save), With(create, other))), 0, isNil)
+		if len(saved) != 1 || saved[0] != -1 {
+			t.Errorf("expected only the placeholder to reach the plain consume, got %v", saved)
+		}
+
+		saved = nil
+		runStepTest(t, Do(With(create, save)), 2, isNil)
+		if len(saved) != 1 || saved[0] != 42 {
+			t.Errorf("expected the live ID, got %v", saved)
+		}
+	})
+
+	t.Run("RecordsNoCheckpoints", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		var deployed []string
+		workflow := Checkpointed(store, deploy(&deployed))
+		runStepTest(t, DryRun(workflow), 1, isNil)
+		expectCompleted(t, store, map[string]bool{"deploy": false})
+		runStepTest(t, workflow, 1, isNil)
+		if len(deployed) != 2 {
+			t.Errorf("expected the live run to deploy, got %v", deployed)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotConverged is returned by [Ensure] when verification does not pass
+// before its retry predicates give up.
+var ErrNotConverged = errors.New("not converged")
+
+// EnsureOutcome is the outcome of an [Ensure] step, recorded in
+// [TraceEvent.Ensure].
+type EnsureOutcome string
+
+const (
+	// EnsurePresent means the check passed, so the action was skipped.
+	EnsurePresent EnsureOutcome = "already present"
+
+	// EnsureCreated means the action ran and verification passed.
+	EnsureCreated EnsureOutcome = "created"
+
+	// EnsureNotConverged means verification did not pass in time.
+	EnsureNotConverged EnsureOutcome = "not converged"
+
+	// EnsureFailed means check or act failed, or verify failed with an
+	// error that stopped the polling (see below).
+	EnsureFailed EnsureOutcome = "failed"
+)
+
+// Ensure returns a [Step] that idempotently brings something into a desired
+// state: it runs check, runs act only if check fails, then polls verify until
+// it passes.
+//
+// This standardizes the common "if not present, create, then wait until it is
+// visible" pattern. If verify is nil, check is used to verify as well.
+//
+// Verification is retried according to predicates, exactly as by [Retry], so
+// the same backoff predicates, retry budgets, and draining rules apply. If
+// verification is still failing when the predicates give up, Ensure fails
+// with an error wrapping [ErrNotConverged]. Errors from verify itself are
+// retried in the same way, except for permanent errors (see [Permanent]) and
+// errors once the context is done, which are returned as they are. Errors
+// from check and act are returned immediately; wrap act in [Retry] to retry
+// it.
+//
+// When tracing is enabled (via [Traced]), the outcome (see [EnsureOutcome])
+// is recorded in [TraceEvent.Ensure] of the innermost enclosing [Named] step,
+// so Ensure should be wrapped directly in a Named step, as in the example
+// below. Without an enclosing Named step, the outcome is not recorded.
+// Verification polls are not recorded as retry events.
+//
+// Example:
+//
+//	flow.Named("bucket", flow.Ensure(
+//	    BucketExists,
+//	    CreateBucket(),
+//	    BucketListed,
+//	    flow.UpTo(10),
+//	    flow.ExponentialBackoff(time.Second, flow.WithMaxDelay(10*time.Second)),
+//	))
+func Ensure[T any](
+	check Predicate[T],
+	act Step[T],
+	verify Predicate[T],
+	predicates ...RetryPredicate,
+) Step[T] {
+	if verify == nil {
+		verify = check
+	}
+	predicates = defaultPredicates(predicates)
+	return func(ctx context.Context, t T) error {
+		present, err := check(ctx, t)
+		if err != nil {
+			recordEnsure(ctx, EnsureFailed)
+			return err
+		}
+		outcome := EnsurePresent
+		if !present {
+			if err := checkBoundary(ctx); err != nil {
+				return err
+			}
+			if err := act(ctx, t); err != nil {
+				recordEnsure(ctx, EnsureFailed)
+				return err
+			}
+			outcome = EnsureCreated
+		}
+
+		err = runRetry(ctx, RetryOptions{}, predicates, false, func(ctx context.Context) error {
+			ok, err := verify(ctx, t)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrNotConverged
+			}
+			return nil
+		})
+		switch {
+		case err == nil:
+		case IsPermanent(err) || ctx.Err() != nil:
+			outcome = EnsureFailed
+		default:
+			outcome = EnsureNotConverged
+			if !errors.Is(err, ErrNotConverged) {
+				err = fmt.Errorf("%w: %w", ErrNotConverged, err)
+			}
+		}
+		recordEnsure(ctx, outcome)
+		return err
+	}
+}
+
+// recordEnsure records the outcome of an Ensure step in the trace.
+func recordEnsure(ctx context.Context, outcome EnsureOutcome) {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if ok && f.trace != nil && f.event != noEvent {
+		f.trace.recordEnsure(f.event, outcome)
+	}
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnsure(t *testing.T) {
+	t.Parallel()
+
+	// exists passes once the counter is positive.
+	exists := func(_ context.Context, c *CountingFlow) (bool, error) {
+		return c.Counter > 0, nil
+	}
+	// visibleAfter passes on the nth call.
+	visibleAfter := func(n int) Predicate[*CountingFlow] {
+		calls := 0
+		return func(context.Context, *CountingFlow) (bool, error) {
+			calls++
+			return calls >= n, nil
+		}
+	}
+	poll := []RetryPredicate{UpTo(3), FixedBackoff(time.Millisecond)}
+
+	testCases := []struct {
+		name    string
+		start   int64
+		verify  Predicate[*CountingFlow]
+		want    EnsureOutcome
+		counter int64
+		err     error
+	}{
+		{
+			name:    "AlreadyPresent",
+			start:   1,
+			verify:  visibleAfter(1),
+			want:    EnsurePresent,
+			counter: 1,
+		},
+		{
+			name:    "Created",
+			verify:  visibleAfter(3),
+			want:    EnsureCreated,
+			counter: 1,
+		},
+		{
+			name:    "NotConverged",
+			verify:  visibleAfter(4),
+			want:    EnsureNotConverged,
+			counter: 1,
+			err:     ErrNotConverged,
+		},
+		{
+			name:    "VerifyDefaultsToCheck",
+			want:    EnsureCreated,
+			counter: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			step := Named("resource", Ensure(exists, Increment(1), tc.verify, poll...))
+			c := CountingFlow{Counter: tc.start}
+			trace, err := Traced(step)(t.Context(), &c)
+			if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+				t.Errorf("got error %v, want %v", err, tc.err)
+			}
+			if c.Counter != tc.counter {
+				t.Errorf("got counter %d, want %d", c.Counter, tc.counter)
+			}
+			if len(trace.Events) != 1 || trace.Events[0].Ensure != tc.want {
+				t.Fatalf("expected outcome %q, got %+v", tc.want, trace.Events)
+			}
+
+			var buf bytes.Buffer
+			if _, err := trace.WriteText(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), "[ENSURE: "+string(tc.want)+"]") {
+				t.Errorf("expected outcome in text output, got %q", buf.String())
+			}
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		failing := FailingPredicate(error1)
+		runStepTest(t, Ensure(failing, Increment(1), nil), 0, matches(error1))
+		runStepTest(t, Ensure(exists, IncrementAndFail(error2), nil), 1, matches(error2))
+
+		// Verify errors are retried, and reported as not converged.
+		var c CountingFlow
+		err := Ensure(exists, Increment(1), failing, poll...)(t.Context(), &c)
+		if !errors.Is(err, ErrNotConverged) || !errors.Is(err, error1) {
+			t.Errorf("expected not converged with error1, got %v", err)
+		}
+
+		// Permanent verify errors are returned as they are.
+		err = Ensure(exists, Increment(1), FailingPredicate(Permanent(error1)), poll...)(t.Context(), &c)
+		if !errors.Is(err, error1) || errors.Is(err, ErrNotConverged) {
+			t.Errorf("expected error1, got %v", err)
+		}
+
+		// So are errors once the context is done.
+		ctx, cancel := context.WithCancel(t.Context())
+		cancelled := func(ctx context.Context, _ *CountingFlow) (bool, error) {
+			cancel()
+			return false, ctx.Err()
+		}
+		err = Ensure(exists, Increment(1), cancelled, poll...)(ctx, &c)
+		if !errors.Is(err, context.Canceled) || errors.Is(err, ErrNotConverged) {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("FailureRecorded", func(t *testing.T) {
+		t.Parallel()
+		for _, step := range []Step[*CountingFlow]{
+			Ensure(FailingPredicate(error1), Increment(1), nil),
+			Ensure(exists, IncrementAndFail(error2), nil),
+			Ensure(exists, Increment(1), FailingPredicate(Permanent(error1))),
+		} {
+			trace, err := Traced(Named("resource", step))(t.Context(), &CountingFlow{})
+			if err == nil {
+				t.Error("expected an error")
+			}
+			if len(trace.Events) != 1 || trace.Events[0].Ensure != EnsureFailed {
+				t.Errorf("expected outcome %q, got %+v", EnsureFailed, trace.Events)
+			}
+		}
+	})
+
+	t.Run("Untraced", func(t *testing.T) {
+		t.Parallel()
+		runStepTest(t, Ensure(exists, Increment(1), nil), 1, isNil)
+	})
+}
@@ -4,7 +4,12 @@ package flow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
 )
 
 // IgnoreError wraps a step to always return nil, even if the step fails.
@@ -18,24 +23,329 @@ func IgnoreError[T any](step Step[T]) Step[T] {
 	}
 }
 
+// StepError is the error returned by [Named] and related functions when the
+// wrapped step fails.
+//
+// It records where and when the failure happened: the full path of step
+// names, the collection element and retry attempt being processed, and how
+// long the step ran. All Named variants return a *StepError, so it can be
+// detected with [errors.As]:
+//
+//	var se *flow.StepError
+//	if errors.As(err, &se) {
+//	    log.Printf("%s failed after %v: %v", strings.Join(se.Path, "."), se.Elapsed, se.Cause)
+//	}
+//
+// Since Named steps nest, a StepError's Cause often contains further
+// StepErrors. Use [StepErrors] or [FailedPath] to find the innermost ones,
+// including across joined errors.
+type StepError struct {
+	// Path is the full hierarchical path of step names, ending with the
+	// name of the step that failed. For example: ["deploy", "db", "migrate"]
+	Path []string
+
+	// Index is the index of the collection element being processed when the
+	// step failed, or -1 if the step was not processing a collection element.
+	//
+	// It is set for steps run by [ForEach] (via [Map]), [Render], [Apply],
+	// and [Collect], and for steps whose error came directly from an
+	// [IndexedError].
+	Index int
+
+	// Attempt is the 1-based attempt number of the innermost enclosing
+	// [Retry] (or variant) when the step failed, or 0 if the step was not
+	// retried.
+	Attempt int
+
+	// Elapsed is how long the step ran before failing.
+	Elapsed time.Duration
+
+	// Cause is the underlying error from the step.
+	Cause error
+}
+
+// Name returns the name of the step that failed, the last element of Path.
+func (e *StepError) Name() string {
+	if len(e.Path) == 0 {
+		return "<unknown>"
+	}
+	return e.Path[len(e.Path)-1]
+}
+
+// Error returns the formatted error message, the step name followed by
+// the cause and separated by a colon.
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name(), e.Cause)
+}
+
+// Unwrap returns the underlying error.
+func (e *StepError) Unwrap() error {
+	return e.Cause
+}
+
+// newStepError creates the StepError for a step that failed in ctx.
+func newStepError(ctx context.Context, path []string, elapsed time.Duration, cause error) *StepError {
+	index, attempt := -1, 0
+	if f, ok := ctx.Value(flowCtxKey{}).(*flowCtx); ok {
+		index, attempt = f.index, f.attempt
+	}
+	if ie := directIndexedError(cause); ie != nil {
+		index = ie.Index
+	}
+	return &StepError{
+		Path:    slices.Clone(path),
+		Index:   index,
+		Attempt: attempt,
+		Elapsed: elapsed,
+		Cause:   cause,
+	}
+}
+
+// directIndexedError returns the first IndexedError in err's chain that is not
+// nested inside another StepError, or nil if there is none.
+func directIndexedError(err error) *IndexedError {
+	for err != nil {
+		switch e := err.(type) {
+		case *IndexedError:
+			return e
+		case *StepError:
+			return nil
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
+}
+
+// StepErrors returns the innermost [StepError] values in err's tree.
+//
+// A StepError is innermost if its cause contains no further StepErrors, so
+// its Path identifies the step where the failure originated. The tree is
+// searched through every wrapped error, including joined errors such as those
+// produced by [DoWith] and [InParallelWith] with JoinErrors enabled, so a
+// failure in each branch is reported separately.
+//
+// Returns nil if err contains no StepErrors.
+func StepErrors(err error) []*StepError {
+	var found []*StepError
+	collectStepErrors(err, &found)
+	return found
+}
+
+// collectStepErrors appends the innermost StepErrors in err's tree to found,
+// reporting whether any were found.
+func collectStepErrors(err error, found *[]*StepError) bool {
+	if err == nil {
+		return false
+	}
+	if se, ok := err.(*StepError); ok {
+		if !collectStepErrors(se.Cause, found) {
+			*found = append(*found, se)
+		}
+		return true
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		foundAny := false
+		for _, inner := range e.Unwrap() {
+			if collectStepErrors(inner, found) {
+				foundAny = true
+			}
+		}
+		return foundAny
+	case interface{ Unwrap() error }:
+		return collectStepErrors(e.Unwrap(), found)
+	}
+	return false
+}
+
+// FailedPath returns the path of the step where err originated: the Path of
+// the first innermost [StepError] in err's tree.
+//
+// Returns nil if err contains no StepErrors.
+//
+// Example:
+//
+//	if err := workflow(ctx, state); err != nil {
+//	    log.Printf("failed at %s", strings.Join(flow.FailedPath(err), "."))
+//	}
+func FailedPath(err error) []string {
+	errs := StepErrors(err)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs[0].Path
+}
+
+// FailedPaths returns the paths of all steps where err originated, one per
+// innermost [StepError] in err's tree.
+//
+// This is useful for joined errors, where several branches may have failed.
+// Returns nil if err contains no StepErrors.
+func FailedPaths(err error) [][]string {
+	var paths [][]string
+	for _, se := range StepErrors(err) {
+		paths = append(paths, se.Path)
+	}
+	return paths
+}
+
+// PermanentError marks an error as permanent, meaning that retrying the step
+// that produced it cannot succeed.
+//
+// [Retry] stops immediately when a step returns a permanent error, regardless
+// of the configured predicates. Use [Permanent] to create one and
+// [IsPermanent] to detect one.
+type PermanentError struct {
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// TransientError marks an error as transient, meaning that retrying the step
+// that produced it may succeed.
+//
+// Use [Transient] to create one and [IsTransient] to detect one. Combined with
+// [OnlyIf], this retries only the errors a step has explicitly classified:
+//
+//	flow.Retry(
+//	    CallExternalAPI(),
+//	    flow.OnlyIf(flow.IsTransient),
+//	    flow.UpTo(5),
+//	)
+type TransientError struct {
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err in a [PermanentError].
+//
+// Returns nil if err is nil, so it can wrap a call's result directly:
+//
+//	return flow.Permanent(validate(req))
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Transient wraps err in a [TransientError].
+//
+// Returns nil if err is nil.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err}
+}
+
+// IsPermanent reports whether any error in err's chain is a [PermanentError].
+//
+// The chain is searched with [errors.As], so permanent errors are found
+// through [StepError], [IndexedError], and joined errors.
+func IsPermanent(err error) bool {
+	var pe *PermanentError
+	return errors.As(err, &pe)
+}
+
+// IsTransient reports whether any error in err's chain is a [TransientError].
+//
+// The chain is searched with [errors.As], so transient errors are found
+// through [StepError], [IndexedError], and joined errors.
+func IsTransient(err error) bool {
+	var te *TransientError
+	return errors.As(err, &te)
+}
+
+// DeadlineError is the cancellation cause used by [WithTimeout],
+// [WithDeadline], and [RetryOptions.PerAttemptTimeout].
+//
+// It records the limit that expired and the step path (see [Names]) at which
+// it was applied. DeadlineError unwraps to [context.DeadlineExceeded].
+type DeadlineError struct {
+	// Path is the step name path where the limit was applied.
+	Path []string
+
+	// Timeout is the duration of the limit, or zero for a deadline.
+	Timeout time.Duration
+
+	// Deadline is the absolute deadline, or zero for a timeout.
+	Deadline time.Time
+}
+
+func (e *DeadlineError) Error() string {
+	var msg string
+	if e.Timeout > 0 {
+		msg = fmt.Sprintf("timeout of %s exceeded", e.Timeout)
+	} else {
+		msg = fmt.Sprintf("deadline %s exceeded", e.Deadline.Format(time.RFC3339))
+	}
+	if len(e.Path) > 0 {
+		msg += fmt.Sprintf(" at %q", strings.Join(e.Path, " > "))
+	}
+	return msg
+}
+
+func (e *DeadlineError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
 // RecoveredPanic is an error type that wraps a panic value.
 type RecoveredPanic struct {
+	// Value is the value passed to panic.
 	Value any
+
+	// Stack is the stack trace of the panicking goroutine, as formatted by
+	// [debug.Stack].
+	Stack []byte
+
+	// Names is the step name path (see [Names]) at the point of the panic.
+	Names []string
 }
 
 func (p *RecoveredPanic) Error() string {
 	return fmt.Sprintf("panic recovered: %v", p.Value)
 }
 
+// newRecoveredPanic wraps a recovered panic value, capturing the current
+// stack. It must be called from the deferred function that recovered it.
+func newRecoveredPanic(ctx context.Context, value any) *RecoveredPanic {
+	return &RecoveredPanic{
+		Value: value,
+		Stack: debug.Stack(),
+		Names: Names(ctx),
+	}
+}
+
 // RecoverPanics wraps a step to recover from panics and convert them to errors.
 //
 // If the step panics, the panic value is wrapped in a [RecoveredPanic] error.
 // This is useful for defensive programming when calling code that may panic.
+//
+// To recover panics throughout a group of steps, see
+// [ParallelOptions.RecoverPanics] and [Options.RecoverPanics].
 func RecoverPanics[T any](step Step[T]) Step[T] {
 	return func(ctx context.Context, t T) (err error) {
 		defer func() {
 			if r := recover(); r != nil {
-				err = &RecoveredPanic{Value: r}
+				err = newRecoveredPanic(ctx, r)
 			}
 		}()
 		return step(ctx, t)
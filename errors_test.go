@@ -5,7 +5,9 @@ package flow
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 )
 
 func TestErrors(t *testing.T) {
@@ -301,3 +303,276 @@ func TestWithPanicRecovery(t *testing.T) {
 		}
 	})
 }
+
+func TestErrorClassification(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name          string
+		err           error
+		wantPermanent bool
+		wantTransient bool
+	}{
+		{
+			name: "Unclassified",
+			err:  error1,
+		},
+		{
+			name:          "Permanent",
+			err:           Permanent(error1),
+			wantPermanent: true,
+		},
+		{
+			name:          "Transient",
+			err:           Transient(error1),
+			wantTransient: true,
+		},
+		{
+			name:          "ThroughStepError",
+			err:           &StepError{Path: []string{"step"}, Cause: Permanent(error1)},
+			wantPermanent: true,
+		},
+		{
+			name:          "ThroughIndexedError",
+			err:           &IndexedError{Index: 2, Err: Transient(error1)},
+			wantTransient: true,
+		},
+		{
+			name: "ThroughNestedChain",
+			err: &StepError{
+				Path:  []string{"outer"},
+				Cause: &IndexedError{Index: 0, Err: &StepError{Path: []string{"outer", "inner"}, Cause: Permanent(error1)}},
+			},
+			wantPermanent: true,
+		},
+		{
+			name:          "ThroughJoinedErrors",
+			err:           errors.Join(error2, Transient(error1)),
+			wantTransient: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := IsPermanent(tc.err); got != tc.wantPermanent {
+				t.Errorf("IsPermanent() = %v, want %v", got, tc.wantPermanent)
+			}
+			if got := IsTransient(tc.err); got != tc.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v", got, tc.wantTransient)
+			}
+			if !errors.Is(tc.err, error1) {
+				t.Errorf("expected %v to match %v", tc.err, error1)
+			}
+		})
+	}
+
+	t.Run("NilPassthrough", func(t *testing.T) {
+		t.Parallel()
+		if err := Permanent(nil); err != nil {
+			t.Errorf("Permanent(nil) = %v, want nil", err)
+		}
+		if err := Transient(nil); err != nil {
+			t.Errorf("Transient(nil) = %v, want nil", err)
+		}
+	})
+
+	t.Run("ErrorMessage", func(t *testing.T) {
+		t.Parallel()
+		if got := Permanent(error1).Error(); got != error1.Error() {
+			t.Errorf("expected message %q, got %q", error1.Error(), got)
+		}
+		if got := Transient(error1).Error(); got != error1.Error() {
+			t.Errorf("expected message %q, got %q", error1.Error(), got)
+		}
+	})
+}
+
+func TestStepError(t *testing.T) {
+	t.Parallel()
+
+	// failAt returns a step that fails only for the given item.
+	failAt := func(bad int) func(int) Step[*CountingFlow] {
+		return func(item int) Step[*CountingFlow] {
+			if item == bad {
+				return Named("item", IncrementAndFail(error1))
+			}
+			return Named("item", Increment(1))
+		}
+	}
+	items := Value[*CountingFlow]([]int{0, 1, 2, 3})
+
+	testCases := []struct {
+		name        string
+		step        Step[*CountingFlow]
+		wantPaths   [][]string
+		wantIndex   int
+		wantAttempt int
+		wantMessage string
+	}{
+		{
+			name:        "Step",
+			step:        Named("a", Named("b", IncrementAndFail(error1))),
+			wantPaths:   [][]string{{"a", "b"}},
+			wantIndex:   -1,
+			wantMessage: "a: b: error 1",
+		},
+		{
+			name: "Extract",
+			step: With(
+				NamedExtract("get", GetCountFailing),
+				SendCount,
+			),
+			wantPaths:   [][]string{{"get"}},
+			wantIndex:   -1,
+			wantMessage: "get: error 1",
+		},
+		{
+			name: "TransformAndConsume",
+			step: Named("outer", Pipeline(
+				GetCount,
+				NamedTransform("parse", func(_ context.Context, _ *CountingFlow, n int64) (int64, error) {
+					return n, nil
+				}),
+				NamedConsume("send", func(_ context.Context, _ *CountingFlow, _ int64) error {
+					return error1
+				}),
+			)),
+			wantPaths:   [][]string{{"outer", "send"}},
+			wantIndex:   -1,
+			wantMessage: "outer: send: error 1",
+		},
+		{
+			name:        "ForEachIndex",
+			step:        Named("process", InSerial(ForEach(items, failAt(2)))),
+			wantPaths:   [][]string{{"process", "item"}},
+			wantIndex:   2,
+			wantMessage: "process: item: error 1",
+		},
+		{
+			name: "ApplyIndex",
+			step: With(
+				items,
+				Apply(NamedConsume("save", func(_ context.Context, _ *CountingFlow, item int) error {
+					if item == 3 {
+						return error1
+					}
+					return nil
+				})),
+			),
+			wantPaths:   [][]string{{"save"}},
+			wantIndex:   3,
+			wantMessage: "element 3: save: error 1",
+		},
+		{
+			name:        "RetryAttempt",
+			step:        Retry(Named("call", IncrementAndFail(error1)), UpTo(3)),
+			wantPaths:   [][]string{{"call"}},
+			wantIndex:   -1,
+			wantAttempt: 3,
+			wantMessage: "call: error 1",
+		},
+		{
+			name: "JoinedSerial",
+			step: DoWith(
+				Options{JoinErrors: true},
+				Named("first", IncrementAndFail(error1)),
+				Named("second", Increment(1)),
+				Named("third", IncrementAndFail(error2)),
+			),
+			wantPaths:   [][]string{{"first"}, {"third"}},
+			wantIndex:   -1,
+			wantMessage: "first: error 1\nthird: error 2",
+		},
+		{
+			name:        "Panic",
+			step:        Named("unsafe", RecoverPanics(PanicWith("boom"))),
+			wantPaths:   [][]string{{"unsafe"}},
+			wantIndex:   -1,
+			wantMessage: "unsafe: panic recovered: boom",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.step(t.Context(), &CountingFlow{})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if err.Error() != tc.wantMessage {
+				t.Errorf("got message %q, want %q", err.Error(), tc.wantMessage)
+			}
+			gotPaths := FailedPaths(err)
+			if fmt.Sprint(gotPaths) != fmt.Sprint(tc.wantPaths) {
+				t.Errorf("got paths %v, want %v", gotPaths, tc.wantPaths)
+			}
+			if fmt.Sprint(FailedPath(err)) != fmt.Sprint(tc.wantPaths[0]) {
+				t.Errorf("got path %v, want %v", FailedPath(err), tc.wantPaths[0])
+			}
+			se := StepErrors(err)[0]
+			if se.Index != tc.wantIndex {
+				t.Errorf("got index %d, want %d", se.Index, tc.wantIndex)
+			}
+			if se.Attempt != tc.wantAttempt {
+				t.Errorf("got attempt %d, want %d", se.Attempt, tc.wantAttempt)
+			}
+		})
+	}
+
+	t.Run("JoinedParallel", func(t *testing.T) {
+		t.Parallel()
+		err := InParallelWith(
+			ParallelOptions{JoinErrors: true},
+			Steps(
+				Named("a", IncrementAndFail(error1)),
+				Named("b", IncrementAndFail(error2)),
+			),
+		)(t.Context(), &CountingFlow{})
+		paths := FailedPaths(err)
+		if len(paths) != 2 {
+			t.Fatalf("expected 2 failed paths, got %v", paths)
+		}
+		got := map[string]bool{paths[0][0]: true, paths[1][0]: true}
+		if !got["a"] || !got["b"] {
+			t.Errorf("expected paths [a] and [b], got %v", paths)
+		}
+	})
+
+	t.Run("ErrorsAs", func(t *testing.T) {
+		t.Parallel()
+		// Every Named variant returns the same pointer type.
+		_, extractErr := NamedExtract("extract", GetCountFailing)(t.Context(), &CountingFlow{})
+		stepErr := Named("step", IncrementAndFail(error1))(t.Context(), &CountingFlow{})
+		for _, err := range []error{extractErr, stepErr} {
+			var se *StepError
+			if !errors.As(err, &se) {
+				t.Errorf("expected *StepError, got %T", err)
+			}
+		}
+	})
+
+	t.Run("Elapsed", func(t *testing.T) {
+		t.Parallel()
+		err := Named("slow", Do(
+			Sleep[*CountingFlow](20*time.Millisecond),
+			IncrementAndFail(error1),
+		))(t.Context(), &CountingFlow{})
+		var se *StepError
+		if !errors.As(err, &se) {
+			t.Fatalf("expected *StepError, got %T", err)
+		}
+		if se.Elapsed < 20*time.Millisecond {
+			t.Errorf("expected elapsed of at least 20ms, got %v", se.Elapsed)
+		}
+	})
+
+	t.Run("NoStepErrors", func(t *testing.T) {
+		t.Parallel()
+		if path := FailedPath(error1); path != nil {
+			t.Errorf("expected nil path, got %v", path)
+		}
+		if paths := FailedPaths(nil); paths != nil {
+			t.Errorf("expected nil paths, got %v", paths)
+		}
+	})
+}
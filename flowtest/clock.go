@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flowtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sam-fredrickson/flow"
+)
+
+// FakeClock is a [flow.Clock] whose time only moves when told to.
+//
+// Install it with [flow.WithClock] to run workflows without real waiting,
+// then move time forward with [FakeClock.Advance] or
+// [FakeClock.AdvanceToNext]. Since the workflow typically runs in another
+// goroutine, use [FakeClock.BlockUntil] to wait for it to start waiting on a
+// timer before advancing.
+//
+// Example:
+//
+//	clock := flowtest.NewFakeClock(time.Unix(0, 0))
+//	done := make(chan error)
+//	go func() {
+//	    done <- flow.WithClock(clock, flow.Retry(
+//	        step,
+//	        flow.UpTo(3),
+//	        flow.ExponentialBackoff(time.Second),
+//	    ))(ctx, state)
+//	}()
+//	for range 2 {
+//	    clock.BlockUntil(1)
+//	    fmt.Println(clock.AdvanceToNext()) // 1s, then 2s
+//	}
+//	err := <-done
+//
+// A FakeClock is safe for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a FakeClock whose current time is start.
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a timer that fires once the clock has been advanced by at
+// least d. A timer with a non-positive duration fires immediately.
+func (c *FakeClock) NewTimer(d time.Duration) flow.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		ch:       make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer whose deadline
+// has been reached, in deadline order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advanceTo(c.now.Add(d))
+}
+
+// AdvanceToNext moves the clock forward to the deadline of the earliest
+// pending timer, fires it, and returns how far the clock moved.
+//
+// Returns zero without moving the clock if no timers are pending.
+func (c *FakeClock) AdvanceToNext() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.timers) == 0 {
+		return 0
+	}
+	next := c.timers[0].deadline
+	for _, t := range c.timers[1:] {
+		if t.deadline.Before(next) {
+			next = t.deadline
+		}
+	}
+	moved := next.Sub(c.now)
+	c.advanceTo(next)
+	return moved
+}
+
+// BlockUntil blocks until at least n timers are pending.
+//
+// This is how a test waits for the workflow under test to reach a point
+// where it is waiting for time to pass.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Pending returns the number of timers that have not yet fired or been stopped.
+func (c *FakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// advanceTo sets the current time, firing due timers. c.mu must be held.
+func (c *FakeClock) advanceTo(now time.Time) {
+	if now.After(c.now) {
+		c.now = now
+	}
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	remaining := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			remaining = append(remaining, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	clear(c.timers[len(remaining):])
+	c.timers = remaining
+}
+
+// remove deletes t from the pending timers, reporting whether it was pending.
+// c.mu must be held.
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is a timer created by a FakeClock.
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
@@ -0,0 +1,359 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flowtest
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/sam-fredrickson/flow"
+)
+
+var errNotYet = errors.New("not yet")
+
+// epoch is the starting time for fake clocks in tests.
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newRand returns a deterministic random number generator.
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+// failTimes returns a step that fails n times, then succeeds.
+func failTimes(n int) flow.Step[*int] {
+	return func(_ context.Context, calls *int) error {
+		*calls++
+		if *calls <= n {
+			return errNotYet
+		}
+		return nil
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("TimersFireInOrder", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		late := clock.NewTimer(2 * time.Second)
+		early := clock.NewTimer(time.Second)
+
+		clock.Advance(time.Second)
+		select {
+		case got := <-early.C():
+			if !got.Equal(epoch.Add(time.Second)) {
+				t.Errorf("got fire time %v, want %v", got, epoch.Add(time.Second))
+			}
+		default:
+			t.Fatal("expected early timer to fire")
+		}
+		select {
+		case <-late.C():
+			t.Fatal("expected late timer not to fire yet")
+		default:
+		}
+		if clock.Pending() != 1 {
+			t.Errorf("expected 1 pending timer, got %d", clock.Pending())
+		}
+
+		if moved := clock.AdvanceToNext(); moved != time.Second {
+			t.Errorf("expected clock to move 1s, got %v", moved)
+		}
+		<-late.C()
+		if !clock.Now().Equal(epoch.Add(2 * time.Second)) {
+			t.Errorf("got now %v, want %v", clock.Now(), epoch.Add(2*time.Second))
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		timer := clock.NewTimer(time.Second)
+		if !timer.Stop() {
+			t.Error("expected Stop to stop a pending timer")
+		}
+		if timer.Stop() {
+			t.Error("expected Stop to report an already stopped timer")
+		}
+		clock.Advance(time.Hour)
+		select {
+		case <-timer.C():
+			t.Error("expected stopped timer not to fire")
+		default:
+		}
+		if moved := clock.AdvanceToNext(); moved != 0 {
+			t.Errorf("expected no movement without timers, got %v", moved)
+		}
+	})
+
+	t.Run("NonPositiveDuration", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		<-clock.NewTimer(0).C()
+		if clock.Pending() != 0 {
+			t.Errorf("expected no pending timers, got %d", clock.Pending())
+		}
+	})
+}
+
+func TestFakeClockWorkflows(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ExponentialBackoffSchedule", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		var calls int
+		done := make(chan error, 1)
+		go func() {
+			done <- flow.WithClock(clock, flow.Retry(
+				failTimes(3),
+				flow.UpTo(5),
+				flow.ExponentialBackoff(time.Second),
+			))(t.Context(), &calls)
+		}()
+
+		want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+		for i, delay := range want {
+			clock.BlockUntil(1)
+			if got := clock.AdvanceToNext(); got != delay {
+				t.Errorf("backoff %d: got %v, want %v", i, got, delay)
+			}
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 4 {
+			t.Errorf("expected 4 calls, got %d", calls)
+		}
+	})
+
+	t.Run("ReconcileSchedule", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		var calls int
+		var runs []time.Time
+		done := make(chan error, 1)
+		go func() {
+			done <- flow.WithClock(clock, flow.Reconcile(failTimes(2), flow.ReconcileOptions{
+				Interval: time.Minute,
+				Backoff:  time.Second,
+				OnRun: func(trace *flow.Trace, _ error) {
+					runs = append(runs, trace.Start)
+					if len(runs) == 5 {
+						cancel()
+					}
+				},
+			}))(ctx, &calls)
+		}()
+
+		// Two failures back off, then successes resync at the interval.
+		want := []time.Duration{time.Second, 2 * time.Second, time.Minute, time.Minute}
+		for i, delay := range want {
+			clock.BlockUntil(1)
+			if got := clock.AdvanceToNext(); got != delay {
+				t.Errorf("wait %d: got %v, want %v", i, got, delay)
+			}
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(runs) != 5 || !runs[4].Equal(epoch.Add(3*time.Second+2*time.Minute)) {
+			t.Errorf("unexpected run times: %v", runs)
+		}
+	})
+
+	t.Run("SeededJitter", func(t *testing.T) {
+		t.Parallel()
+		// schedule records the jittered delays for a given seed.
+		schedule := func(seed uint64) []time.Duration {
+			clock := NewFakeClock(epoch)
+			var calls int
+			done := make(chan error, 1)
+			go func() {
+				done <- flow.WithClock(clock, flow.WithRand(
+					newRand(seed),
+					flow.Retry(
+						failTimes(3),
+						flow.UpTo(5),
+						flow.ExponentialBackoff(time.Second, flow.WithFullJitter()),
+					),
+				))(context.Background(), &calls)
+			}()
+			var delays []time.Duration
+			for range 3 {
+				clock.BlockUntil(1)
+				delays = append(delays, clock.AdvanceToNext())
+			}
+			<-done
+			return delays
+		}
+
+		first, second := schedule(42), schedule(42)
+		for i := range first {
+			if first[i] != second[i] {
+				t.Errorf("backoff %d: got %v and %v for the same seed", i, first[i], second[i])
+			}
+			if first[i] > time.Second<<i {
+				t.Errorf("backoff %d: %v exceeds the uncapped delay", i, first[i])
+			}
+		}
+	})
+
+	t.Run("WithTimeout", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		done := make(chan error, 1)
+		go func() {
+			done <- flow.WithClock(clock, flow.WithTimeout(
+				time.Minute,
+				flow.Sleep[*int](time.Hour),
+			))(t.Context(), new(int))
+		}()
+
+		// One timer for the timeout, one for the sleep.
+		clock.BlockUntil(2)
+		clock.Advance(time.Minute)
+		err := <-done
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		var deadlineErr *flow.DeadlineError
+		if !errors.As(err, &deadlineErr) || deadlineErr.Timeout != time.Minute {
+			t.Errorf("expected DeadlineError with 1m timeout, got %v", err)
+		}
+	})
+
+	t.Run("DerivedContextErr", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		errs := make(chan error, 2)
+		step := flow.WithTimeout(time.Minute, func(ctx context.Context, _ *int) error {
+			// Contexts derived from the step's report the deadline too.
+			child, cancel := context.WithCancel(ctx)
+			defer cancel()
+			<-child.Done()
+			errs <- ctx.Err()
+			errs <- child.Err()
+			return nil
+		})
+		done := make(chan error, 1)
+		go func() {
+			done <- flow.WithClock(clock, step)(t.Context(), new(int))
+		}()
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, name := range []string{"step", "derived"} {
+			if err := <-errs; err != context.DeadlineExceeded {
+				t.Errorf("%s context: got %v, want %v", name, err, context.DeadlineExceeded)
+			}
+		}
+	})
+
+	t.Run("RetryTrace", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		var trace *flow.Trace
+		workflow := flow.WithClock(clock, flow.Spawn(
+			flow.Traced(flow.Named("call", flow.Retry(
+				flow.Do(flow.Sleep[*int](5*time.Second), failTimes(2)),
+				flow.UpTo(3),
+				flow.FixedBackoff(10*time.Second),
+			))),
+			func(_ context.Context, tr *flow.Trace) error {
+				trace = tr
+				return nil
+			},
+		))
+		done := make(chan error, 1)
+		go func() {
+			done <- workflow(t.Context(), new(int))
+		}()
+		// Three attempts and two backoffs.
+		for range 5 {
+			clock.BlockUntil(1)
+			clock.AdvanceToNext()
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// The 35s step was really three 5s attempts and two 10s backoffs.
+		call := trace.Roots()[0]
+		if call.Duration != 35*time.Second {
+			t.Errorf("got call duration %v, want 35s", call.Duration)
+		}
+		retry := trace.Children(call.ID)[0]
+		want := []struct {
+			name     string
+			duration time.Duration
+		}{
+			{"attempt", 5 * time.Second},
+			{"backoff", 10 * time.Second},
+			{"attempt", 5 * time.Second},
+			{"backoff", 10 * time.Second},
+			{"attempt", 5 * time.Second},
+		}
+		children := trace.Children(retry.ID)
+		if len(children) != len(want) {
+			t.Fatalf("expected %d events within the retry, got %d", len(want), len(children))
+		}
+		for i, child := range children {
+			name := string(child.Kind)
+			if name != want[i].name || child.Duration != want[i].duration {
+				t.Errorf("event %d: got %s (%v), want %s (%v)", i, name, child.Duration, want[i].name, want[i].duration)
+			}
+			if name == "backoff" && (child.Attrs["delay"] != 10*time.Second || child.Attrs["reason"] != flow.BackoffFixed) {
+				t.Errorf("event %d: unexpected attributes %v", i, child.Attrs)
+			}
+		}
+	})
+
+	t.Run("TraceDurations", func(t *testing.T) {
+		t.Parallel()
+		clock := NewFakeClock(epoch)
+		var trace *flow.Trace
+		workflow := flow.WithClock(clock, flow.Spawn(
+			flow.Traced(flow.Do(
+				flow.Named("first", flow.Sleep[*int](time.Second)),
+				flow.Named("second", flow.Sleep[*int](3*time.Second)),
+			)),
+			func(_ context.Context, tr *flow.Trace) error {
+				trace = tr
+				return nil
+			},
+		))
+		done := make(chan error, 1)
+		go func() {
+			done <- workflow(t.Context(), new(int))
+		}()
+		for range 2 {
+			clock.BlockUntil(1)
+			clock.AdvanceToNext()
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !trace.Start.Equal(epoch) {
+			t.Errorf("got trace start %v, want %v", trace.Start, epoch)
+		}
+		if trace.Duration != 4*time.Second {
+			t.Errorf("got trace duration %v, want 4s", trace.Duration)
+		}
+		want := []time.Duration{time.Second, 3 * time.Second}
+		for i, event := range trace.Events {
+			if event.Duration != want[i] {
+				t.Errorf("event %d: got duration %v, want %v", i, event.Duration, want[i])
+			}
+		}
+	})
+}
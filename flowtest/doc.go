@@ -0,0 +1,8 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package flowtest provides helpers for testing workflows built with flow.
+//
+// [FakeClock] replaces the system clock (see [flow.WithClock]), so tests can
+// assert exact backoff schedules, timeouts, and trace durations without
+// waiting in real time.
+package flowtest
@@ -211,6 +211,14 @@ func isRecoveredPanic(testErr error) error {
 	return nil
 }
 
+// isPermanent validates that the error is classified as permanent.
+func isPermanent(testErr error) error {
+	if !IsPermanent(testErr) {
+		return fmt.Errorf("expected permanent error, got %v", testErr)
+	}
+	return nil
+}
+
 // contains returns a validator that checks if the error message contains the given substring.
 func contains(substring string) func(error) error {
 	return func(testErr error) error {
@@ -0,0 +1,446 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SkipReasonReplayed is the [TraceEvent.SkipReason] recorded for named
+// extracts and transforms whose result was replayed from a [Journal].
+const SkipReasonReplayed = "replayed"
+
+// A Codec encodes and decodes the step results recorded in a [Journal].
+type Codec interface {
+	// Marshal encodes v.
+	Marshal(v any) ([]byte, error)
+
+	// Unmarshal decodes data into the value pointed to by v.
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec returns the [Codec] backed by encoding/json. It is the default
+// codec of a [Journal].
+func JSONCodec() Codec {
+	return jsonCodec{}
+}
+
+// jsonCodec is the default Codec, backed by encoding/json.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// NondeterminismError is returned by [Journaled] when a workflow replayed
+// from a journal does not follow the same sequence of steps as the run that
+// recorded it.
+//
+// It is wrapped in a [PermanentError], since running the steps again cannot
+// make them follow the recorded sequence, so an enclosing [Retry] does not
+// retry it.
+type NondeterminismError struct {
+	// Path is the step path (see [Checkpointed]) where the divergence was
+	// detected.
+	Path []string
+
+	// Reason describes the divergence.
+	Reason string
+}
+
+func (e *NondeterminismError) Error() string {
+	return fmt.Sprintf("nondeterministic workflow at %q: %s", strings.Join(e.Path, " > "), e.Reason)
+}
+
+// JournalOption configures a [Journal].
+type JournalOption func(*journalOptions)
+
+// journalOptions holds configuration for journals.
+type journalOptions struct {
+	// Codec encodes recorded results. Defaults to JSONCodec.
+	Codec Codec
+}
+
+// WithCodec configures a [Journal] to encode results with codec instead of
+// [JSONCodec].
+func WithCodec(codec Codec) JournalOption {
+	return func(opts *journalOptions) {
+		opts.Codec = codec
+	}
+}
+
+// journalRecord is a single line of a journal.
+type journalRecord struct {
+	// Path is the step path of the recorded step.
+	Path []string `json:"path"`
+
+	// Occurrence distinguishes repeated runs of the same path, such as loop
+	// iterations, counting from zero.
+	Occurrence int `json:"n,omitempty"`
+
+	// Value holds the encoded result if it is valid JSON, which keeps JSON
+	// journals readable; Data holds it otherwise.
+	Value json.RawMessage `json:"value,omitempty"`
+	Data  []byte          `json:"data,omitempty"`
+}
+
+func (r *journalRecord) key() string {
+	return fmt.Sprintf("%s\x00#%d", pathKey(r.Path), r.Occurrence)
+}
+
+// A Journal records the results of named extracts and transforms so that a
+// [Journaled] workflow can replay them when it is run again.
+//
+// The journal is stored as JSON Lines, one record per result. Records are
+// appended as results are produced, so the journal survives a crash. A
+// record left half-written by a crash is ignored when the journal is opened.
+//
+// A Journal is safe for concurrent use, but should be used by one workflow
+// run at a time.
+type Journal struct {
+	mu      sync.Mutex
+	codec   Codec
+	w       io.Writer
+	closer  io.Closer
+	records map[string]*journalRecord
+	order   []*journalRecord
+
+	// newline is whether the input did not end with a newline, which must
+	// then be written before the next record.
+	newline bool
+}
+
+// NewJournal creates a [Journal] that replays the records read from r and
+// appends new records to w.
+//
+// This is useful for storing journals somewhere other than a local file. See
+// [OpenJournal] for the common case. A last line of r cut short by a crash is
+// ignored, and new records start on the next line, but the storage must be
+// truncated to remove it before the journal is read again, as OpenJournal
+// does.
+func NewJournal(r io.Reader, w io.Writer, opts ...JournalOption) (*Journal, error) {
+	j := newJournal(w, opts)
+	if r == nil {
+		return j, nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	if _, err := j.load(data); err != nil {
+		return nil, err
+	}
+	j.newline = len(data) > 0 && data[len(data)-1] != '\n'
+	return j, nil
+}
+
+// OpenJournal opens the journal file at path, creating it if it does not
+// exist. Existing records are replayed, and new records are appended.
+//
+// The file is synced after each record, and truncated when it is opened to
+// remove a record left half-written by a crash. Close the journal when the
+// workflow is done.
+func OpenJournal(path string, opts ...JournalOption) (*Journal, error) {
+	// #nosec G304 -- the journal file is chosen by the caller
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	j, err := openJournal(file, opts)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// openJournal reads the journal in file, truncating a last record that was
+// cut short, and appends to it.
+func openJournal(file *os.File, opts []JournalOption) (*Journal, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	j := newJournal(file, opts)
+	n, err := j.load(data)
+	if err != nil {
+		return nil, err
+	}
+	if n < len(data) {
+		if err := file.Truncate(int64(n)); err != nil {
+			return nil, fmt.Errorf("failed to truncate journal: %w", err)
+		}
+	}
+	j.newline = n > 0 && data[n-1] != '\n'
+	j.closer = file
+	return j, nil
+}
+
+// newJournal creates an empty Journal that appends records to w.
+func newJournal(w io.Writer, opts []JournalOption) *Journal {
+	options := journalOptions{Codec: JSONCodec()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Journal{
+		codec:   options.Codec,
+		w:       w,
+		records: make(map[string]*journalRecord),
+	}
+}
+
+// load decodes the records in data, and returns the length of the data up to
+// the end of the last record. A malformed last line, left by a crash while
+// appending, is ignored; any other malformed line is an error.
+func (j *Journal) load(data []byte) (int, error) {
+	end, offset := 0, 0
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		offset += len(line) + 1
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		record := &journalRecord{}
+		if err := json.Unmarshal(line, record); err != nil {
+			if i == len(lines)-1 {
+				// The last record was cut short by a crash.
+				break
+			}
+			return 0, fmt.Errorf("failed to parse journal line %d: %w", i+1, err)
+		}
+		j.records[record.key()] = record
+		j.order = append(j.order, record)
+		end = min(offset, len(data))
+	}
+	return end, nil
+}
+
+// Close closes the journal's file, if it was opened with [OpenJournal].
+func (j *Journal) Close() error {
+	if j.closer == nil {
+		return nil
+	}
+	return j.closer.Close()
+}
+
+// Len returns the number of records in the journal.
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.order)
+}
+
+// append encodes and writes a record. The caller must hold j.mu.
+func (j *Journal) append(record *journalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal record: %w", err)
+	}
+	if j.newline {
+		line = append([]byte{'\n'}, line...)
+	}
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	j.newline = false
+	if syncer, ok := j.w.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			return fmt.Errorf("failed to sync journal: %w", err)
+		}
+	}
+	j.records[record.key()] = record
+	j.order = append(j.order, record)
+	return nil
+}
+
+// Journaled runs a workflow with durable execution: the results of its
+// [NamedExtract] and [NamedTransform] steps are recorded in journal, and
+// replayed from it when the workflow is run again.
+//
+// When a named extract or transform has a recorded result, the result is
+// decoded and returned without calling the function, and, if tracing is
+// enabled, a trace event is recorded with [TraceEvent.SkipReason] set to
+// [SkipReasonReplayed]. Otherwise the function runs live and its result, if
+// it succeeds, is appended to the journal. A workflow that failed part-way
+// through therefore replays everything up to the point of failure and runs
+// live from there, with later steps seeing the same values (such as the IDs
+// of resources created earlier) as in the original run.
+//
+// Results are matched by step path (see [Checkpointed]) and by occurrence,
+// so that repeated runs of the same path, such as loop iterations, replay in
+// order. Only results count as occurrences, so the failed attempts of a step
+// within [Retry] do not. Replay therefore requires the workflow to be deterministic: a named
+// extract or transform reached out of the recorded order fails with a
+// *[NondeterminismError], as does a successful run that finishes without
+// reaching every recorded step. Journaled steps should therefore not run
+// concurrently, as within [InParallel]. The records within steps skipped by
+// [Checkpointed] are not expected to be reached.
+//
+// Plain steps are not journaled; combine Journaled with [Checkpointed] to
+// skip steps that completed.
+//
+// Example:
+//
+//	journal, err := flow.OpenJournal("setup.journal.jsonl")
+//	if err != nil {
+//	    return err
+//	}
+//	defer journal.Close()
+//	err = flow.Journaled(journal, SetupEnvironment())(ctx, state)
+func Journaled[T any](journal *Journal, step Step[T]) Step[T] {
+	return func(ctx context.Context, t T) error {
+		journal.mu.Lock()
+		run := &journalRun{
+			journal:     journal,
+			occurrences: make(map[string]int),
+			end:         len(journal.order),
+		}
+		journal.mu.Unlock()
+		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+		f2 := newFlowCtx(ctx, f)
+		f2.journal = run
+
+		if err := step(f2, t); err != nil {
+			return err
+		}
+		return run.checkComplete()
+	}
+}
+
+// journalRun tracks a single run of a Journaled workflow.
+type journalRun struct {
+	journal *Journal
+
+	// The fields below are guarded by the journal's mutex.
+
+	occurrences map[string]int
+
+	// next is the position in the journal's order of the next record the
+	// run should reach, and end the number of records before the run.
+	next, end int
+
+	// skipped holds the paths of the subtrees skipped by checkpoints, whose
+	// records are never reached.
+	skipped [][]string
+}
+
+// replay decodes the recorded result for the next occurrence of path into
+// result, reporting whether there was one.
+//
+// The step must be the next one recorded in the journal; otherwise replay
+// returns a NondeterminismError. Occurrences are counted only as results are
+// replayed or recorded, so that the failed attempts of a retried step do not
+// change the occurrences of later ones.
+func (r *journalRun) replay(path []string, result any) (bool, error) {
+	j := r.journal
+	j.mu.Lock()
+	pk := pathKey(path)
+	occurrence := r.occurrences[pk]
+	r.skipUnreachable()
+	if r.next == r.end {
+		// Past the recorded steps, so the step runs live.
+		j.mu.Unlock()
+		return false, nil
+	}
+	expected := j.order[r.next]
+	if !slices.Equal(expected.Path, path) || expected.Occurrence != occurrence {
+		j.mu.Unlock()
+		return false, Permanent(&NondeterminismError{
+			Path:   path,
+			Reason: fmt.Sprintf("expected recorded step %q", strings.Join(expected.Path, " > ")),
+		})
+	}
+	data := []byte(expected.Value)
+	if len(data) == 0 {
+		data = expected.Data
+	}
+	if err := j.codec.Unmarshal(data, result); err != nil {
+		j.mu.Unlock()
+		return false, Permanent(&NondeterminismError{
+			Path:   path,
+			Reason: fmt.Sprintf("failed to decode recorded result: %v", err),
+		})
+	}
+	r.next++
+	r.occurrences[pk]++
+	j.mu.Unlock()
+	return true, nil
+}
+
+// skip records that the subtree at path was skipped by a checkpoint, so that
+// the records within it are not expected.
+func (r *journalRun) skip(path []string) {
+	j := r.journal
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r.skipped = append(r.skipped, path)
+}
+
+// skipUnreachable moves past the records within skipped subtrees. The caller
+// must hold the journal's mutex.
+func (r *journalRun) skipUnreachable() {
+	for r.next < r.end {
+		recorded := r.journal.order[r.next].Path
+		if !slices.ContainsFunc(r.skipped, func(path []string) bool {
+			return len(recorded) >= len(path) && slices.Equal(recorded[:len(path)], path)
+		}) {
+			return
+		}
+		r.next++
+	}
+}
+
+// record appends the result of a step that ran live to the journal, as the
+// next occurrence of its path.
+func (r *journalRun) record(path []string, result any) error {
+	j := r.journal
+	data, err := j.codec.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result for journal: %w", err)
+	}
+	record := &journalRecord{Path: path}
+	if json.Valid(data) {
+		record.Value = data
+	} else {
+		record.Data = data
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	pk := pathKey(path)
+	record.Occurrence = r.occurrences[pk]
+	if err := j.append(record); err != nil {
+		return err
+	}
+	r.occurrences[pk]++
+	return nil
+}
+
+// checkComplete returns a NondeterminismError if the run did not reach every
+// record that was in the journal.
+func (r *journalRun) checkComplete() error {
+	j := r.journal
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	r.skipUnreachable()
+	if r.next < r.end {
+		return Permanent(&NondeterminismError{
+			Path:   j.order[r.next].Path,
+			Reason: "recorded step was not reached",
+		})
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// gobbledCodec encodes strings in a form that is not valid JSON.
+type gobbledCodec struct{}
+
+func (gobbledCodec) Marshal(v any) ([]byte, error) {
+	return []byte("~" + *v.(*string)), nil
+}
+
+func (gobbledCodec) Unmarshal(data []byte, v any) error {
+	s, ok := strings.CutPrefix(string(data), "~")
+	if !ok {
+		return errors.New("bad data")
+	}
+	*v.(*string) = s
+	return nil
+}
+
+// createAndUse is a workflow that creates a resource, whose ID is derived
+// from the counter, then uses it. It fails using the resource while *fail is
+// set, and stores the ID it used in *used.
+func createAndUse(fail *bool, used *int64) Step[*CountingFlow] {
+	create := NamedExtract("create", func(_ context.Context, c *CountingFlow) (int64, error) {
+		c.Counter++
+		return c.Counter * 100, nil
+	})
+	use := NamedConsume("use", func(_ context.Context, _ *CountingFlow, id int64) error {
+		if *fail {
+			return error1
+		}
+		*used = id
+		return nil
+	})
+	return With(create, use)
+}
+
+func TestJournaled(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ReplaysAfterFailure", func(t *testing.T) {
+		t.Parallel()
+		journal, err := NewJournal(nil, &bytes.Buffer{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fail, used := true, int64(0)
+		workflow := Journaled(journal, createAndUse(&fail, &used))
+		runStepTest(t, workflow, 1, matches(error1))
+		if journal.Len() != 1 {
+			t.Fatalf("expected 1 record, got %d", journal.Len())
+		}
+
+		// The rerun starts from a fresh counter, so a live create would
+		// return a different ID.
+		fail = false
+		c := CountingFlow{Counter: 5}
+		trace, err := Traced(workflow)(t.Context(), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Counter != 5 || used != 100 {
+			t.Errorf("expected create to be replayed with ID 100, got counter %d, ID %d", c.Counter, used)
+		}
+		replayed := trace.FindEvent(NameMatches("create"))
+		if replayed == nil || replayed.SkipReason != SkipReasonReplayed {
+			t.Errorf("expected create to be replayed, got %+v", replayed)
+		}
+		if ran := trace.FindEvent(NameMatches("use")); ran == nil || ran.SkipReason != "" {
+			t.Errorf("expected use to run, got %+v", ran)
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "journal.jsonl")
+		fail, used := true, int64(0)
+		run := func() error {
+			journal, err := OpenJournal(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if err := journal.Close(); err != nil {
+					t.Error(err)
+				}
+			}()
+			return Journaled(journal, createAndUse(&fail, &used))(t.Context(), &CountingFlow{Counter: 1})
+		}
+
+		if err := run(); !errors.Is(err, error1) {
+			t.Fatalf("expected error1, got %v", err)
+		}
+		fail = false
+		if err := run(); err != nil {
+			t.Fatal(err)
+		}
+		if used != 200 {
+			t.Errorf("expected replayed ID 200, got %d", used)
+		}
+	})
+
+	t.Run("Occurrences", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		journal, _ := NewJournal(nil, &buf)
+		var seen []int64
+		loop := While(
+			Not(CountEquals(3)),
+			With(
+				NamedExtract("next", func(_ context.Context, c *CountingFlow) (int64, error) {
+					return c.Counter + 10, nil
+				}),
+				func(_ context.Context, c *CountingFlow, n int64) error {
+					c.Counter++
+					seen = append(seen, n)
+					return nil
+				},
+			),
+		)
+		runStepTest(t, Journaled(journal, loop), 3, isNil)
+
+		replay, _ := NewJournal(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+		seen = nil
+		runStepTest(t, Journaled(replay, loop), 3, isNil)
+		if len(seen) != 3 || seen[0] != 10 || seen[1] != 11 || seen[2] != 12 {
+			t.Errorf("expected iterations to replay in order, got %v", seen)
+		}
+	})
+
+	t.Run("Nondeterminism", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		journal, _ := NewJournal(nil, &buf)
+		a := NamedExtract("a", GetCount)
+		b := NamedExtract("b", GetCount)
+		runStepTest(t, Journaled(journal, With(a, SendCount)), 0, isNil)
+
+		replay, _ := NewJournal(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+		var c CountingFlow
+		err := Journaled(replay, With(b, SendCount))(t.Context(), &c)
+		var nondet *NondeterminismError
+		if !errors.As(err, &nondet) || len(nondet.Path) != 1 || nondet.Path[0] != "b" || !strings.Contains(nondet.Reason, `"a"`) {
+			t.Errorf("expected nondeterminism at b, got %v", err)
+		}
+	})
+
+	t.Run("OutOfOrder", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		journal, _ := NewJournal(nil, &buf)
+		a := NamedExtract("a", GetCount)
+		b := NamedExtract("b", GetCount)
+		runStepTest(t, Journaled(journal, Do(With(a, SendCount), With(b, SendCount))), 0, isNil)
+
+		// The steps are reached in the other order, which fails at once,
+		// before the step after them runs.
+		replay, _ := NewJournal(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+		var c CountingFlow
+		err := Journaled(replay, Do(With(b, SendCount), With(a, SendCount), Increment(1)))(t.Context(), &c)
+		var nondet *NondeterminismError
+		if !errors.As(err, &nondet) || nondet.Path[0] != "b" {
+			t.Errorf("expected nondeterminism at b, got %v", err)
+		}
+		if c.Counter != 0 {
+			t.Error("expected the workflow to stop at the first step out of order")
+		}
+	})
+
+	t.Run("Retried", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		journal, _ := NewJournal(nil, &buf)
+		var calls int
+		get := NamedExtract("get", func(context.Context, *CountingFlow) (int64, error) {
+			calls++
+			if calls == 1 {
+				return 0, error1
+			}
+			return 7, nil
+		})
+		setCount := func(_ context.Context, c *CountingFlow, n int64) error {
+			c.Counter = n
+			return nil
+		}
+		workflow := With(RetryExtract(get, UpTo(2)), setCount)
+		runStepTest(t, Journaled(journal, workflow), 7, isNil)
+
+		// The failed attempt does not count as an occurrence, so the
+		// result replays at once.
+		if !strings.Contains(buf.String(), `{"path":["get"],"value":7}`) {
+			t.Errorf("expected the first occurrence of get in journal, got %s", buf.String())
+		}
+		replay, _ := NewJournal(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+		retries := 0
+		countRetries := func(context.Context, int, error) bool {
+			retries++
+			return true
+		}
+		workflow = With(RetryExtract(get, countRetries, UpTo(2)), setCount)
+		runStepTest(t, Journaled(replay, workflow), 7, isNil)
+		if calls != 2 || retries != 0 {
+			t.Errorf("expected the result to replay without retries, got %d calls and %d retries", calls, retries)
+		}
+	})
+
+	t.Run("NondeterminismNotRetried", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		journal, _ := NewJournal(nil, &buf)
+		runStepTest(t, Journaled(journal, With(NamedExtract("a", GetCount), SendCount)), 0, isNil)
+
+		replay, _ := NewJournal(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+		retries := 0
+		countRetries := func(context.Context, int, error) bool {
+			retries++
+			return true
+		}
+		var c CountingFlow
+		err := Journaled(replay, Retry(With(NamedExtract("b", GetCount), SendCount), countRetries, UpTo(3)))(t.Context(), &c)
+		var nondet *NondeterminismError
+		if !errors.As(err, &nondet) || !IsPermanent(err) {
+			t.Errorf("expected permanent nondeterminism error, got %v", err)
+		}
+		if retries != 0 {
+			t.Errorf("expected no retries, got %d", retries)
+		}
+	})
+
+	t.Run("ConcurrentOccurrences", func(t *testing.T) {
+		t.Parallel()
+		// The first occurrence finishes after the second starts, and each
+		// is recorded once, numbered in the order they finished.
+		var buf bytes.Buffer
+		journal, _ := NewJournal(nil, &buf)
+		release := make(chan struct{})
+		var calls atomic.Int64
+		get := NamedExtract("get", func(context.Context, *CountingFlow) (int64, error) {
+			n := calls.Add(1)
+			if n == 1 {
+				<-release
+			} else {
+				close(release)
+			}
+			return n, nil
+		})
+		runStepTest(t, Journaled(journal, InParallel(Steps(With(get, SendCount), With(get, SendCount)))), 0, isNil)
+
+		replay, err := NewJournal(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for occurrence := range 2 {
+			if _, ok := replay.records[(&journalRecord{Path: []string{"get"}, Occurrence: occurrence}).key()]; !ok {
+				t.Errorf("expected occurrence %d in journal:\n%s", occurrence, buf.String())
+			}
+		}
+	})
+
+	t.Run("Codec", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		journal, _ := NewJournal(nil, &buf, WithCodec(gobbledCodec{}))
+		var got string
+		hello := NamedExtract("hello", func(context.Context, *CountingFlow) (string, error) {
+			return "hello", nil
+		})
+		save := func(_ context.Context, _ *CountingFlow, s string) error {
+			got = s
+			return nil
+		}
+		runStepTest(t, Journaled(journal, With(hello, save)), 0, isNil)
+		if !strings.Contains(buf.String(), `"data":`) {
+			t.Errorf("expected non-JSON result to be stored as data, got %s", buf.String())
+		}
+
+		replay, _ := NewJournal(bytes.NewReader(buf.Bytes()), &bytes.Buffer{}, WithCodec(gobbledCodec{}))
+		got = ""
+		runStepTest(t, Journaled(replay, With(hello, save)), 0, isNil)
+		if got != "hello" {
+			t.Errorf("expected replayed hello, got %q", got)
+		}
+
+		// Decoding with the wrong codec is reported as nondeterminism.
+		wrong, _ := NewJournal(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+		var c CountingFlow
+		err := Journaled(wrong, With(hello, save))(t.Context(), &c)
+		var nondet *NondeterminismError
+		if !errors.As(err, &nondet) {
+			t.Errorf("expected nondeterminism error, got %v", err)
+		}
+	})
+
+	t.Run("InvalidJournal", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewJournal(strings.NewReader("{}\nnot json\n"), &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("expected parse error on line 2, got %v", err)
+		}
+	})
+
+	t.Run("TornRecord", func(t *testing.T) {
+		t.Parallel()
+		// A crash while appending leaves the last record cut short, or
+		// complete but without its newline.
+		for _, last := range []string{`{"path":["cou`, `{"path":["other"],"value":1}`} {
+			path := filepath.Join(t.TempDir(), "journal.jsonl")
+			data := `{"path":["create"],"value":200}` + "\n" + last
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			journal, err := OpenJournal(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 1 + strings.Count(last, "}")
+			if journal.Len() != want {
+				t.Errorf("%s: expected %d records, got %d", last, want, journal.Len())
+			}
+
+			// Later records are appended on a line of their own.
+			err = journal.append(&journalRecord{Path: []string{"count"}, Value: []byte("3")})
+			if closeErr := journal.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			reopened, err := OpenJournal(path)
+			if err != nil {
+				t.Fatalf("%s: %v", last, err)
+			}
+			if reopened.Len() != want+1 {
+				t.Errorf("%s: expected %d records after appending, got %d", last, want+1, reopened.Len())
+			}
+			_ = reopened.Close()
+		}
+	})
+
+	t.Run("WithCheckpoints", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryCheckpointStore()
+		journal, _ := NewJournal(nil, &bytes.Buffer{})
+		fail, used := true, int64(0)
+		workflow := Journaled(journal, Checkpointed(store, Do(
+			Named("setup", Increment(1)),
+			createAndUse(&fail, &used),
+		)))
+		runStepTest(t, workflow, 2, matches(error1))
+		fail = false
+		runStepTest(t, workflow, 0, isNil)
+		if used != 200 {
+			t.Errorf("expected replayed ID 200, got %d", used)
+		}
+	})
+
+	t.Run("WithCheckpointedExtracts", func(t *testing.T) {
+		t.Parallel()
+		// The extract within the checkpointed step is neither replayed nor
+		// run, but its record is not reported as unreached.
+		store := NewMemoryCheckpointStore()
+		journal, _ := NewJournal(nil, &bytes.Buffer{})
+		fail, used := true, int64(0)
+		workflow := Journaled(journal, Checkpointed(store, Do(
+			Named("setup", With(NamedExtract("id", GetCount), SendCount)),
+			createAndUse(&fail, &used),
+		)))
+		runStepTest(t, workflow, 1, matches(error1))
+		fail = false
+		runStepTest(t, workflow, 0, isNil)
+		if used != 100 {
+			t.Errorf("expected replayed ID 100, got %d", used)
+		}
+	})
+}
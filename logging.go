@@ -7,7 +7,6 @@ import (
 	"log"
 	"log/slog"
 	"strings"
-	"time"
 )
 
 // Logger returns the [log.Logger] from the context, or [log.Default] if none is set.
@@ -104,9 +103,10 @@ func WithLogging[T any](step Step[T]) Step[T] {
 		logger := Logger(ctx)
 
 		logger.Printf("[%s] starting step\n", fullName)
-		start := time.Now()
+		clock := ClockFrom(ctx)
+		start := clock.Now()
 		err := step(ctx, t)
-		duration := time.Since(start)
+		duration := clock.Now().Sub(start)
 		logger.Printf("[%s] finished step (took %v)\n", fullName, duration)
 		return err
 	}
@@ -204,9 +204,10 @@ func WithSlogging[T any](level slog.Level, step Step[T]) Step[T] {
 		logger := Slogger(ctx)
 
 		logger.Log(ctx, level, "starting step", "name", fullName)
-		start := time.Now()
+		clock := ClockFrom(ctx)
+		start := clock.Now()
 		err := step(ctx, t)
-		duration := time.Since(start)
+		duration := clock.Now().Sub(start)
 		logger.Log(ctx, level, "finished step", "name", fullName, "duration_ms", duration.Milliseconds())
 		return err
 	}
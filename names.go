@@ -5,6 +5,7 @@ package flow
 import (
 	"context"
 	"runtime"
+	"slices"
 	"strings"
 )
 
@@ -47,7 +48,9 @@ func addName(ctx context.Context, name string) (context.Context, []string) {
 //
 // The name is prepended to the error message of the [Step], separated by a colon.
 // For example, if the [Step] returns an error "invalid config", the name is
-// prepended to the error message: "example: invalid config".
+// prepended to the error message: "example: invalid config". The returned
+// error is a *[StepError] recording the step's path, timing, and position
+// within collections and retries.
 //
 // Named also maintains a stack of step names in the context, which can be
 // retrieved using [Names]. When Named decorators are nested, each appends
@@ -56,33 +59,114 @@ func addName(ctx context.Context, name string) (context.Context, []string) {
 // When tracing is enabled (via [Traced]), Named automatically records execution
 // events including timing and errors.
 //
+// If the step panics inside a group run with RecoverPanics enabled (see
+// [ParallelOptions] and [Options]), Named converts the panic into a
+// [RecoveredPanic] carrying the stack and step path at the point of the panic.
+//
 // This is useful for debugging and logging.
 func Named[T any](name string, step Step[T]) Step[T] {
 	return func(ctx context.Context, t T) error {
 		ctx, newNames := addName(ctx, name)
+		return runNamed(ctx, newNames, nil, func() error {
+			return step(ctx, t)
+		})
+	}
+}
+
+// runNamed runs the body of a named step, whose name stack is names.
+//
+// It records a trace event for the step (if tracing is enabled) and wraps
+// any error in a *[StepError]. If the body panics and panic recovery is
+// enabled in ctx, the panic is converted to a [RecoveredPanic]; otherwise it
+// propagates after the trace event is marked as panicked.
+//
+// For steps that produce a value, result points to the variable the body
+// stores it in; it is nil for steps that do not. If checkpointing is enabled
+// (see [Checkpointed]), steps without a result are skipped if they already
+// completed, and recorded as completed if they succeed. If journaling is
+// enabled (see [Journaled]), results are replayed from the journal if it
+// has them, and recorded in it otherwise. Nothing is recorded in dry-run mode
+// (see [DryRun]).
+func runNamed(ctx context.Context, names []string, result any, body func() error) error {
+	clock := ClockFrom(ctx)
+	start := clock.Now()
+	// ctx is the step's own flowCtx, created by addName, so it may still be
+	// updated with the step's path and trace event.
+	f, _ := ctx.(*flowCtx)
+
+	// Checkpointing and journaling
+	var path []string
+	if f != nil && f.tracksPath() {
+		path = append(slices.Clone(f.stepPath), names[len(names)-1])
+		f.stepPath = path
+
+		var skip bool
+		var reason string
+		var err error
+		switch {
+		case result == nil && f.checkpoints != nil:
+			skip, err = f.checkpoints.skip(ctx, path)
+			reason = SkipReasonCheckpointed
+			if skip && f.journal != nil {
+				f.journal.skip(path)
+			}
+		case result != nil && f.journal != nil:
+			skip, err = f.journal.replay(path, result)
+			reason = SkipReasonReplayed
+		}
+		if err != nil {
+			return newStepError(ctx, names, clock.Now().Sub(start), err)
+		}
+		if skip {
+			if trace := getTrace(ctx); trace != nil {
+				trace.recordSkip(ctx, names, reason)
+			}
+			return nil
+		}
+	}
 
-		// Trace instrumentation
-		trace := getTrace(ctx)
-		var idx eventIdx
-		if trace != nil {
-			idx = trace.newEvent(newNames)
+	// Trace instrumentation
+	trace := getTrace(ctx)
+	var idx eventIdx
+	if trace != nil {
+		idx = trace.newEvent(ctx, names, KindStep)
+		if f != nil {
+			f.event = idx
 		}
+	}
 
-		var err error
-		func() {
-			defer func() {
-				if trace != nil {
-					trace.recordFinish(idx, err)
+	var err error
+	func() {
+		finished := false
+		defer func() {
+			panicked := !finished
+			if panicked && recoversPanics(ctx) {
+				r := recover()
+				panicked = r != nil
+				if panicked {
+					err = newRecoveredPanic(ctx, r)
 				}
-			}()
-			err = step(ctx, t)
+			}
+			if trace != nil {
+				trace.recordFinish(ctx, idx, err, panicked)
+			}
 		}()
-
-		if err != nil {
-			return NamedError{Name: name, Err: err}
+		err = body()
+		finished = true
+	}()
+
+	if err == nil && path != nil && !f.dryRun {
+		switch {
+		case result == nil && f.checkpoints != nil:
+			err = f.checkpoints.store.MarkCompleted(ctx, path)
+		case result != nil && f.journal != nil:
+			err = f.journal.record(path, result)
 		}
-		return nil
 	}
+	if err != nil {
+		return newStepError(ctx, names, clock.Now().Sub(start), err)
+	}
+	return nil
 }
 
 // NamedTransform wraps a [Transform] with a name.
@@ -91,6 +175,8 @@ func Named[T any](name string, step Step[T]) Step[T] {
 // colon. For example, if the [Transform] returns an error "invalid config", the
 // name is prepended to the error message: "example: invalid config".
 //
+// As with [Named], the returned error is a *[StepError].
+//
 // When tracing is enabled (via [Traced]), NamedTransform automatically records
 // execution events including timing and errors.
 //
@@ -101,29 +187,12 @@ func NamedTransform[T any, In any, Out any](
 ) Transform[T, In, Out] {
 	return func(ctx context.Context, t T, in In) (Out, error) {
 		ctx, newNames := addName(ctx, name)
-
-		// Trace instrumentation
-		trace := getTrace(ctx)
-		var idx eventIdx
-		if trace != nil {
-			idx = trace.newEvent(newNames)
-		}
-
 		var out Out
-		var err error
-		func() {
-			defer func() {
-				if trace != nil {
-					trace.recordFinish(idx, err)
-				}
-			}()
+		err := runNamed(ctx, newNames, &out, func() (err error) {
 			out, err = transform(ctx, t, in)
-		}()
-
-		if err != nil {
-			return out, &NamedError{Name: name, Err: err}
-		}
-		return out, nil
+			return err
+		})
+		return out, err
 	}
 }
 
@@ -133,6 +202,8 @@ func NamedTransform[T any, In any, Out any](
 // colon. For example, if the [Extract] returns an error "invalid config", the
 // name is prepended to the error message: "example: invalid config".
 //
+// As with [Named], the returned error is a *[StepError].
+//
 // When tracing is enabled (via [Traced]), NamedExtract automatically records
 // execution events including timing and errors.
 //
@@ -143,29 +214,12 @@ func NamedExtract[T any, U any](
 ) Extract[T, U] {
 	return func(ctx context.Context, t T) (U, error) {
 		ctx, newNames := addName(ctx, name)
-
-		// Trace instrumentation
-		trace := getTrace(ctx)
-		var idx eventIdx
-		if trace != nil {
-			idx = trace.newEvent(newNames)
-		}
-
 		var u U
-		var err error
-		func() {
-			defer func() {
-				if trace != nil {
-					trace.recordFinish(idx, err)
-				}
-			}()
+		err := runNamed(ctx, newNames, &u, func() (err error) {
 			u, err = extract(ctx, t)
-		}()
-
-		if err != nil {
-			return u, &NamedError{Name: name, Err: err}
-		}
-		return u, nil
+			return err
+		})
+		return u, err
 	}
 }
 
@@ -175,6 +229,8 @@ func NamedExtract[T any, U any](
 // colon. For example, if the [Consume] returns an error "invalid config", the
 // name is prepended to the error message: "example: invalid config".
 //
+// As with [Named], the returned error is a *[StepError].
+//
 // When tracing is enabled (via [Traced]), NamedConsume automatically records
 // execution events including timing and errors.
 //
@@ -185,28 +241,9 @@ func NamedConsume[T any, U any](
 ) Consume[T, U] {
 	return func(ctx context.Context, t T, u U) error {
 		ctx, newNames := addName(ctx, name)
-
-		// Trace instrumentation
-		trace := getTrace(ctx)
-		var idx eventIdx
-		if trace != nil {
-			idx = trace.newEvent(newNames)
-		}
-
-		var err error
-		func() {
-			defer func() {
-				if trace != nil {
-					trace.recordFinish(idx, err)
-				}
-			}()
-			err = consume(ctx, t, u)
-		}()
-
-		if err != nil {
-			return NamedError{Name: name, Err: err}
-		}
-		return nil
+		return runNamed(ctx, newNames, nil, func() error {
+			return consume(ctx, t, u)
+		})
 	}
 }
 
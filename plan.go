@@ -0,0 +1,296 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrPlanRejected is returned by [PlanAndApply] when the plan is not
+// approved.
+var ErrPlanRejected = errors.New("plan rejected")
+
+// A Plan is the set of changes proposed by the components of a plan-then-apply
+// workflow. See [PlanAndApply].
+//
+// A Plan can be encoded as JSON, so that it can be saved, reviewed, and
+// applied later with [ApplyPlan].
+type Plan struct {
+	// Components holds the changes proposed by each component, in the order
+	// the components were given.
+	Components []PlannedComponent `json:"components"`
+}
+
+// PlannedComponent holds the changes proposed by one component of a [Plan].
+type PlannedComponent struct {
+	// Name is the name of the component's [Planner].
+	Name string `json:"name"`
+
+	// Changes holds the proposed changes, each encoded as JSON.
+	Changes []json.RawMessage `json:"changes"`
+}
+
+// Empty reports whether the plan proposes no changes.
+func (p *Plan) Empty() bool {
+	return p.Len() == 0
+}
+
+// Len returns the total number of changes in the plan.
+func (p *Plan) Len() int {
+	n := 0
+	for _, c := range p.Components {
+		n += len(c.Changes)
+	}
+	return n
+}
+
+// StalePlanError is returned by [ApplyPlan] and [PlanAndApply] when the
+// changes proposed by a component differ from those in the plan being
+// applied, typically because the infrastructure changed after the plan was
+// made.
+type StalePlanError struct {
+	// Component is the name of the component whose changes differ, or ""
+	// if the plan's components do not match the workflow's.
+	Component string
+
+	// Reason describes the difference.
+	Reason string
+}
+
+func (e *StalePlanError) Error() string {
+	if e.Component == "" {
+		return "plan is stale: " + e.Reason
+	}
+	return fmt.Sprintf("plan is stale: component %q: %s", e.Component, e.Reason)
+}
+
+// A PlanComponent is one component of a plan-then-apply workflow.
+//
+// PlanComponent is sealed: its methods are unexported, so [Planner] is its
+// only implementation. It exists so that the components of a workflow can
+// propose changes of different types; to add a component, create a Planner
+// with the change type it needs.
+type PlanComponent[T any] interface {
+	// componentName returns the name of the component.
+	componentName() string
+
+	// plan proposes the component's changes, encoded as JSON.
+	plan(ctx context.Context, t T) ([]json.RawMessage, error)
+
+	// apply decodes and applies the given changes.
+	apply(ctx context.Context, t T, changes []json.RawMessage) error
+}
+
+// A Planner is a component of a plan-then-apply workflow, whose proposed
+// changes are of type C. See [PlanAndApply].
+//
+// Changes must be encodable as JSON. Give changes a key (see [Checkpointed])
+// to identify them in checkpoint paths.
+type Planner[T any, C any] struct {
+	// Name identifies the component within the plan. It must be unique
+	// among the components of a workflow.
+	Name string
+
+	// Plan proposes the changes needed to bring the component to its
+	// desired state. It should not change anything.
+	Plan Extract[T, []C]
+
+	// Apply makes a single change.
+	Apply Consume[T, C]
+}
+
+func (p Planner[T, C]) componentName() string {
+	return p.Name
+}
+
+func (p Planner[T, C]) plan(ctx context.Context, t T) ([]json.RawMessage, error) {
+	changes, err := p.Plan(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]json.RawMessage, 0, len(changes))
+	for i, change := range changes {
+		data, err := json.Marshal(change)
+		if err != nil {
+			return nil, &IndexedError{Index: i, Err: fmt.Errorf("failed to encode change: %w", err)}
+		}
+		encoded = append(encoded, data)
+	}
+	return encoded, nil
+}
+
+func (p Planner[T, C]) apply(ctx context.Context, t T, encoded []json.RawMessage) error {
+	changes := make([]C, len(encoded))
+	for i, data := range encoded {
+		if err := json.Unmarshal(data, &changes[i]); err != nil {
+			return &IndexedError{Index: i, Err: fmt.Errorf("failed to decode change: %w", err)}
+		}
+	}
+	return Apply(p.Apply)(ctx, t, changes)
+}
+
+// MakePlan returns an [Extract] that runs the plan phase of a plan-then-apply
+// workflow: it collects the changes proposed by each component into a
+// [Plan]. See [PlanAndApply].
+//
+// The extract is named "plan", and each component's plan is named after the
+// component.
+func MakePlan[T any](components ...PlanComponent[T]) Extract[T, *Plan] {
+	return NamedExtract("plan", func(ctx context.Context, t T) (*Plan, error) {
+		plan := &Plan{Components: make([]PlannedComponent, 0, len(components))}
+		for _, c := range components {
+			changes, err := NamedExtract(c.componentName(), c.plan)(ctx, t)
+			if err != nil {
+				return nil, err
+			}
+			plan.Components = append(plan.Components, PlannedComponent{
+				Name:    c.componentName(),
+				Changes: changes,
+			})
+		}
+		return plan, nil
+	})
+}
+
+// ApplyPlan returns a [Step] that runs the apply phase of a plan-then-apply
+// workflow: it makes exactly the changes in plan, which was made by
+// [MakePlan] with the same components, possibly by an earlier process. See
+// [PlanAndApply].
+//
+// Before changing anything, ApplyPlan plans every component again and fails
+// with a *[StalePlanError] if any proposes different changes from those in
+// plan. Components then apply their changes in order, one change at a time.
+//
+// The step is named "apply", and each component's changes are applied in a
+// [SideEffect] step named after the component, so that [DryRun] skips them
+// all. Within [Checkpointed], a rerun after a failure skips the components
+// that were already applied, without planning them again, and resumes with
+// the component that failed. That component is planned again in full, so if
+// it failed after applying some of its changes, the plan is stale and a new
+// one must be made.
+func ApplyPlan[T any](plan *Plan, components ...PlanComponent[T]) Step[T] {
+	return Named("apply", func(ctx context.Context, t T) error {
+		if len(plan.Components) != len(components) {
+			return &StalePlanError{
+				Reason: fmt.Sprintf(
+					"plan has %d components, workflow has %d",
+					len(plan.Components), len(components),
+				),
+			}
+		}
+		for i, c := range components {
+			if err := checkStale(ctx, t, c, plan.Components[i]); err != nil {
+				return err
+			}
+		}
+		for i, c := range components {
+			if err := checkBoundary(ctx); err != nil {
+				return err
+			}
+			changes := plan.Components[i].Changes
+			err := SideEffect(c.componentName(), func(ctx context.Context, t T) error {
+				return c.apply(ctx, t, changes)
+			})(ctx, t)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkStale plans a component again, returning a StalePlanError if it
+// proposes different changes from planned. Components already applied, as
+// recorded by Checkpointed, are not planned again.
+func checkStale[T any](ctx context.Context, t T, c PlanComponent[T], planned PlannedComponent) error {
+	name := c.componentName()
+	if planned.Name != name {
+		return &StalePlanError{
+			Component: name,
+			Reason:    fmt.Sprintf("plan has component %q in its place", planned.Name),
+		}
+	}
+	// A component applied by an earlier run no longer proposes its changes,
+	// but is skipped rather than applied again.
+	if applied, err := completedBefore(ctx, name); err != nil || applied {
+		return err
+	}
+	current, err := c.plan(ctx, t)
+	if err != nil {
+		return err
+	}
+	if len(current) != len(planned.Changes) {
+		return &StalePlanError{
+			Component: name,
+			Reason:    fmt.Sprintf("planned %d changes, now %d", len(planned.Changes), len(current)),
+		}
+	}
+	for i := range current {
+		// Compact the planned change, which may have been reformatted
+		// since it was saved.
+		var want bytes.Buffer
+		if err := json.Compact(&want, planned.Changes[i]); err != nil {
+			return &StalePlanError{
+				Component: name,
+				Reason:    fmt.Sprintf("change %d is not valid JSON: %v", i, err),
+			}
+		}
+		if !bytes.Equal(want.Bytes(), current[i]) {
+			return &StalePlanError{
+				Component: name,
+				Reason:    fmt.Sprintf("change %d is now %s, planned %s", i, current[i], want.Bytes()),
+			}
+		}
+	}
+	return nil
+}
+
+// PlanAndApply runs a plan-then-apply workflow.
+//
+// In the plan phase, each component proposes the changes it needs (see
+// [MakePlan]). If there are any, the resulting [Plan] is passed to approve,
+// which may show it to a human, evaluate a policy, or save it for review. If
+// approve returns false, PlanAndApply fails with [ErrPlanRejected]. Otherwise
+// the apply phase makes exactly the planned changes (see [ApplyPlan]),
+// failing with a *[StalePlanError] if the components now propose different
+// ones.
+//
+// Components are [Planner] values, which may propose changes of different
+// types.
+//
+// Example:
+//
+//	flow.PlanAndApply(
+//	    func(ctx context.Context, env *Env, plan *flow.Plan) (bool, error) {
+//	        return promptYesNo(plan)
+//	    },
+//	    flow.Planner[*Env, DNSChange]{Name: "dns", Plan: PlanDNS, Apply: ApplyDNS},
+//	    flow.Planner[*Env, BucketChange]{Name: "buckets", Plan: PlanBuckets, Apply: ApplyBucket},
+//	)
+func PlanAndApply[T any](
+	approve Transform[T, *Plan, bool],
+	components ...PlanComponent[T],
+) Step[T] {
+	makePlan := MakePlan(components...)
+	return func(ctx context.Context, t T) error {
+		plan, err := makePlan(ctx, t)
+		if err != nil {
+			return err
+		}
+		if plan.Empty() {
+			return nil
+		}
+		approved, err := approve(ctx, t, plan)
+		if err != nil {
+			return err
+		}
+		if !approved {
+			return ErrPlanRejected
+		}
+		return ApplyPlan(plan, components...)(ctx, t)
+	}
+}
@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// infra is the state of a plan-then-apply test workflow: the records that
+// exist, and the log of applied changes.
+type infra struct {
+	records map[string]string
+	buckets []string
+	applied []string
+}
+
+// recordChange sets a record to a value.
+type recordChange struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (c recordChange) CheckpointKey() string { return c.Name }
+
+// infraComponents returns planners that converge the records and buckets of
+// an infra toward the desired ones.
+func infraComponents(want map[string]string, buckets ...string) []PlanComponent[*infra] {
+	records := Planner[*infra, recordChange]{
+		Name: "records",
+		Plan: func(_ context.Context, s *infra) ([]recordChange, error) {
+			var changes []recordChange
+			for _, name := range []string{"a", "b", "c"} {
+				if value, ok := want[name]; ok && s.records[name] != value {
+					changes = append(changes, recordChange{Name: name, Value: value})
+				}
+			}
+			return changes, nil
+		},
+		Apply: func(_ context.Context, s *infra, c recordChange) error {
+			s.records[c.Name] = c.Value
+			s.applied = append(s.applied, "record "+c.Name)
+			return nil
+		},
+	}
+	bucketPlanner := Planner[*infra, string]{
+		Name: "buckets",
+		Plan: func(_ context.Context, s *infra) ([]string, error) {
+			var missing []string
+			for _, b := range buckets {
+				if !strings.Contains(strings.Join(s.buckets, ","), b) {
+					missing = append(missing, b)
+				}
+			}
+			return missing, nil
+		},
+		Apply: func(_ context.Context, s *infra, b string) error {
+			s.buckets = append(s.buckets, b)
+			s.applied = append(s.applied, "bucket "+b)
+			return nil
+		},
+	}
+	return []PlanComponent[*infra]{records, bucketPlanner}
+}
+
+// approveAll approves every plan, recording it in *seen.
+func approveAll(seen **Plan) Transform[*infra, *Plan, bool] {
+	return func(_ context.Context, _ *infra, plan *Plan) (bool, error) {
+		*seen = plan
+		return true, nil
+	}
+}
+
+func TestPlanAndApply(t *testing.T) {
+	t.Parallel()
+
+	t.Run("AppliesApprovedPlan", func(t *testing.T) {
+		t.Parallel()
+		s := &infra{records: map[string]string{"a": "1"}}
+		var seen *Plan
+		components := infraComponents(map[string]string{"a": "1", "b": "2"}, "logs")
+		err := PlanAndApply(approveAll(&seen), components...)(t.Context(), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen == nil || seen.Len() != 2 {
+			t.Fatalf("expected a plan with 2 changes, got %+v", seen)
+		}
+		if got := strings.Join(s.applied, ","); got != "record b,bucket logs" {
+			t.Errorf("unexpected changes applied: %s", got)
+		}
+
+		// Converged: nothing to approve or apply.
+		seen = nil
+		s.applied = nil
+		if err := PlanAndApply(approveAll(&seen), components...)(t.Context(), s); err != nil {
+			t.Fatal(err)
+		}
+		if seen != nil || len(s.applied) != 0 {
+			t.Errorf("expected no approval or changes, got plan %+v and changes %v", seen, s.applied)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		t.Parallel()
+		s := &infra{records: map[string]string{}}
+		reject := func(context.Context, *infra, *Plan) (bool, error) { return false, nil }
+		err := PlanAndApply(reject, infraComponents(map[string]string{"a": "1"})...)(t.Context(), s)
+		if !errors.Is(err, ErrPlanRejected) {
+			t.Errorf("expected ErrPlanRejected, got %v", err)
+		}
+		if len(s.applied) != 0 {
+			t.Errorf("expected no changes, got %v", s.applied)
+		}
+	})
+
+	t.Run("StaleAfterApproval", func(t *testing.T) {
+		t.Parallel()
+		s := &infra{records: map[string]string{}}
+		// Someone else creates the record while the plan is being reviewed.
+		approve := func(_ context.Context, s *infra, _ *Plan) (bool, error) {
+			s.records["a"] = "1"
+			return true, nil
+		}
+		err := PlanAndApply(approve, infraComponents(map[string]string{"a": "1"})...)(t.Context(), s)
+		var stale *StalePlanError
+		if !errors.As(err, &stale) || stale.Component != "records" {
+			t.Errorf("expected stale records, got %v", err)
+		}
+		if len(s.applied) != 0 {
+			t.Errorf("expected no changes, got %v", s.applied)
+		}
+	})
+
+	t.Run("SavedPlan", func(t *testing.T) {
+		t.Parallel()
+		s := &infra{records: map[string]string{}}
+		components := infraComponents(map[string]string{"a": "1", "c": "3"}, "logs")
+		plan, err := MakePlan(components...)(t.Context(), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var loaded Plan
+		if err := json.Unmarshal(saved, &loaded); err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyPlan(&loaded, components...)(t.Context(), s); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(s.applied, ","); got != "record a,record c,bucket logs" {
+			t.Errorf("unexpected changes applied: %s", got)
+		}
+
+		// Once applied, the plan is stale.
+		err = ApplyPlan(&loaded, components...)(t.Context(), s)
+		var stale *StalePlanError
+		if !errors.As(err, &stale) || !strings.Contains(err.Error(), "planned 2 changes, now 0") {
+			t.Errorf("expected stale plan, got %v", err)
+		}
+	})
+
+	t.Run("ResumesPartialApply", func(t *testing.T) {
+		t.Parallel()
+		s := &infra{records: map[string]string{}}
+		components := infraComponents(map[string]string{"a": "1"}, "logs")
+		plan, err := MakePlan(components...)(t.Context(), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The records are applied, then the buckets fail.
+		fail := true
+		buckets := components[1].(Planner[*infra, string])
+		apply := buckets.Apply
+		buckets.Apply = func(ctx context.Context, s *infra, b string) error {
+			if fail {
+				return error1
+			}
+			return apply(ctx, s, b)
+		}
+		store := NewMemoryCheckpointStore()
+		workflow := Checkpointed(store, ApplyPlan(plan, components[0], buckets))
+		if err := workflow(t.Context(), s); !errors.Is(err, error1) {
+			t.Fatalf("expected error1, got %v", err)
+		}
+
+		// The rerun skips the records, which no longer propose changes, and
+		// applies the buckets.
+		fail = false
+		if err := workflow(t.Context(), s); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(s.applied, ","); got != "record a,bucket logs" {
+			t.Errorf("unexpected changes applied: %s", got)
+		}
+	})
+
+	t.Run("MismatchedComponents", func(t *testing.T) {
+		t.Parallel()
+		s := &infra{records: map[string]string{}}
+		components := infraComponents(map[string]string{"a": "1"})
+		plan, _ := MakePlan(components...)(t.Context(), s)
+
+		err := ApplyPlan(plan, components[0])(t.Context(), s)
+		var stale *StalePlanError
+		if !errors.As(err, &stale) || stale.Component != "" {
+			t.Errorf("expected stale plan with no component, got %v", err)
+		}
+
+		err = ApplyPlan(plan, components[1], components[0])(t.Context(), s)
+		if !errors.As(err, &stale) || stale.Component != "buckets" {
+			t.Errorf("expected stale buckets, got %v", err)
+		}
+	})
+
+	t.Run("Traced", func(t *testing.T) {
+		t.Parallel()
+		s := &infra{records: map[string]string{}}
+		var seen *Plan
+		step := PlanAndApply(approveAll(&seen), infraComponents(map[string]string{"a": "1"})...)
+		trace, err := Traced(DryRun(step))(t.Context(), s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range []string{"plan.records", "plan.buckets", "apply"} {
+			if trace.FindEvent(PathMatches(path)) == nil {
+				t.Errorf("expected an event for %s", path)
+			}
+		}
+		wouldRun := trace.Filter(Skipped(SkipReasonDryRun))
+		if len(wouldRun.Events) != 2 || len(s.applied) != 0 {
+			t.Errorf("expected both components to be skipped in a dry run, got %+v", wouldRun.Events)
+		}
+	})
+}
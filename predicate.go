@@ -16,9 +16,14 @@ import (
 // If using parallel steps, ensure that access to T is thread-safe.
 type Predicate[T any] = func(context.Context, T) (bool, error)
 
+// SkipReasonCondition is the [TraceEvent.SkipReason] recorded for steps
+// skipped by [When] or [Unless].
+const SkipReasonCondition = "condition not met"
+
 // When runs the given step only if the predicate returns true.
 //
 // If the predicate returns false, the step is skipped and nil is returned.
+// If tracing is enabled, the skip is recorded as an event named "when".
 // If the predicate returns an error, that error is propagated.
 func When[T any](
 	predicate Predicate[T],
@@ -32,6 +37,7 @@ func When[T any](
 		if ok {
 			return step(ctx, t)
 		}
+		skipCondition(ctx, "when")
 		return nil
 	}
 }
@@ -39,6 +45,7 @@ func When[T any](
 // Unless runs the given step only if the predicate returns false.
 //
 // If the predicate returns true, the step is skipped and nil is returned.
+// If tracing is enabled, the skip is recorded as an event named "unless".
 // If the predicate returns an error, that error is propagated.
 func Unless[T any](
 	predicate Predicate[T],
@@ -52,10 +59,19 @@ func Unless[T any](
 		if !ok {
 			return step(ctx, t)
 		}
+		skipCondition(ctx, "unless")
 		return nil
 	}
 }
 
+// skipCondition records a trace event for a step skipped by When or Unless.
+func skipCondition(ctx context.Context, name string) {
+	if trace := getTrace(ctx); trace != nil {
+		_, names := addName(ctx, name)
+		trace.recordSkip(ctx, names, SkipReasonCondition)
+	}
+}
+
 // While repeatedly executes the step as long as the predicate returns true.
 //
 // The predicate is evaluated before each iteration. If it returns false or
@@ -84,6 +100,9 @@ func While[T any](
 			if !ok {
 				return nil
 			}
+			if err := checkBoundary(ctx); err != nil {
+				return err
+			}
 			if err := step(ctx, t); err != nil {
 				return err
 			}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"time"
+)
+
+// ReconcileOptions configures [Reconcile].
+type ReconcileOptions struct {
+	// Interval is how long to wait after a successful run before running
+	// again, to resync with the actual state.
+	//
+	// Values less than or equal to zero disable resyncing, so that the step
+	// runs again only when triggered.
+	Interval time.Duration
+
+	// Backoff is the delay before running again after a failed run. It
+	// doubles with each consecutive failure, as with [ExponentialBackoff].
+	//
+	// Values less than or equal to zero default to one second.
+	Backoff time.Duration
+
+	// BackoffOptions configure the delay after a failed run, for example to
+	// add jitter or cap it with [WithMaxDelay]. Delays requested by the error
+	// (see [RetryAfter]) are honored.
+	BackoffOptions []BackoffOption
+
+	// Trigger runs the step early, without waiting for the interval or
+	// backoff to elapse. A trigger received during a run causes another run
+	// as soon as it finishes. Use a buffered channel and non-blocking sends
+	// to coalesce triggers.
+	Trigger <-chan struct{}
+
+	// OnRun, if not nil, is called after each run with the run's trace and
+	// error.
+	OnRun func(trace *Trace, err error)
+}
+
+// Reconcile returns a [Step] that runs step repeatedly, in the manner of a
+// controller that continuously converges actual state toward desired state.
+//
+// The step runs immediately, then again after each resync interval, after a
+// backoff if it failed, or early when triggered (see [ReconcileOptions]).
+// Errors do not stop the loop: they are delivered to [ReconcileOptions.OnRun]
+// along with each run's own [Trace].
+//
+// Runs never overlap, even if the returned step is itself run concurrently.
+//
+// Reconcile stops between runs when its context is done, returning nil, or
+// when the workflow starts draining (see [Drain]), returning [ErrDraining].
+// A run in progress when the context is done receives the cancellation as
+// usual.
+//
+// Example:
+//
+//	trigger := make(chan struct{}, 1)
+//	watcher.OnChange(func() {
+//	    select {
+//	    case trigger <- struct{}{}:
+//	    default:
+//	    }
+//	})
+//	err := flow.Reconcile(ConvergeCluster(), flow.ReconcileOptions{
+//	    Interval: 5 * time.Minute,
+//	    Backoff:  time.Second,
+//	    BackoffOptions: []flow.BackoffOption{
+//	        flow.WithFullJitter(),
+//	        flow.WithMaxDelay(time.Minute),
+//	    },
+//	    Trigger: trigger,
+//	    OnRun: func(trace *flow.Trace, err error) {
+//	        if err != nil {
+//	            trace.Filter(flow.HasError()).WriteText(os.Stderr)
+//	        }
+//	    },
+//	})(ctx, cluster)
+func Reconcile[T any](step Step[T], opts ReconcileOptions) Step[T] {
+	cfg := backoffConfig{
+		multiplier: 2.0, // default multiplier
+	}
+	for _, opt := range opts.BackoffOptions {
+		opt(&cfg)
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	traced := Traced(step)
+
+	// running holds a token while a run is in progress.
+	running := make(chan struct{}, 1)
+
+	return func(ctx context.Context, t T) error {
+		failures := 0
+		for {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := checkBoundary(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+
+			select {
+			case running <- struct{}{}:
+			case <-ctx.Done():
+				return nil
+			}
+			trace, err := traced(ctx, t)
+			<-running
+
+			if opts.OnRun != nil {
+				opts.OnRun(trace, err)
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if err != nil {
+				// Runs may fail indefinitely, so the exponent stops growing
+				// once the delay would overflow.
+				if _, ok := cfg.exponentialDelay(backoff, failures+1); ok {
+					failures++
+				}
+				delay, _ := cfg.exponentialDelay(backoff, failures)
+				delay = cfg.finalDelay(ctx, delay, err)
+				if delay > 0 {
+					waitReconcile(ctx, delay, opts.Trigger)
+				}
+			} else {
+				failures = 0
+				waitReconcile(ctx, opts.Interval, opts.Trigger)
+			}
+		}
+	}
+}
+
+// waitReconcile waits until delay elapses, a trigger is received, ctx is
+// done, or the workflow starts draining. A delay less than or equal to zero
+// waits without a time limit.
+func waitReconcile(ctx context.Context, delay time.Duration, trigger <-chan struct{}) {
+	var timeout <-chan time.Time
+	if delay > 0 {
+		timer := ClockFrom(ctx).NewTimer(delay)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+	var draining <-chan struct{}
+	if f, ok := ctx.Value(flowCtxKey{}).(*flowCtx); ok && f.drain != nil {
+		draining = f.drain.started
+	}
+
+	select {
+	case <-timeout:
+	case <-trigger:
+	case <-draining:
+	case <-ctx.Done():
+	}
+}
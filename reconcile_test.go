@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Triggered", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		trigger := make(chan struct{})
+		var errs []error
+		ran := make(chan struct{}, 3)
+		step := Named("converge", func(_ context.Context, c *CountingFlow) error {
+			c.Counter++
+			if c.Counter == 2 {
+				return error1
+			}
+			return nil
+		})
+		var c CountingFlow
+		done := make(chan error, 1)
+		go func() {
+			done <- Reconcile(step, ReconcileOptions{
+				Backoff: time.Hour,
+				Trigger: trigger,
+				OnRun: func(trace *Trace, err error) {
+					if len(trace.Events) != 1 || trace.Events[0].Names[0] != "converge" {
+						t.Errorf("expected a trace of the run, got %+v", trace.Events)
+					}
+					errs = append(errs, err)
+					ran <- struct{}{}
+				},
+			})(ctx, &c)
+		}()
+
+		// Without an interval, each further run needs a trigger, even to cut
+		// the backoff after the second run fails.
+		<-ran
+		trigger <- struct{}{}
+		<-ran
+		trigger <- struct{}{}
+		<-ran
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("expected a clean stop, got %v", err)
+		}
+		if len(errs) != 3 || errs[0] != nil || !errors.Is(errs[1], error1) || errs[2] != nil {
+			t.Errorf("expected runs to succeed, fail, and succeed, got %v", errs)
+		}
+	})
+
+	t.Run("Resync", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		var c CountingFlow
+		err := Reconcile(Increment(1), ReconcileOptions{
+			Interval: time.Millisecond,
+			OnRun: func(*Trace, error) {
+				if atomic.LoadInt64(&c.Counter) == 3 {
+					cancel()
+				}
+			},
+		})(ctx, &c)
+		if err != nil || c.Counter != 3 {
+			t.Errorf("expected 3 runs and a clean stop, got %d runs and %v", c.Counter, err)
+		}
+	})
+
+	t.Run("NoOverlap", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		var active, maxActive, runs int64
+		step := Reconcile(func(context.Context, *CountingFlow) error {
+			n := atomic.AddInt64(&active, 1)
+			for {
+				m := atomic.LoadInt64(&maxActive)
+				if n <= m || atomic.CompareAndSwapInt64(&maxActive, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&active, -1)
+			if atomic.AddInt64(&runs, 1) >= 10 {
+				cancel()
+			}
+			return nil
+		}, ReconcileOptions{Interval: time.Microsecond})
+
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = step(ctx, &CountingFlow{})
+			}()
+		}
+		wg.Wait()
+		if maxActive != 1 {
+			t.Errorf("expected runs not to overlap, got %d at once", maxActive)
+		}
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		t.Parallel()
+		drain := NewDrain()
+		var c CountingFlow
+		err := WithDrain(drain, Reconcile(Increment(1), ReconcileOptions{
+			OnRun: func(*Trace, error) { drain.Start(0) },
+		}))(t.Context(), &c)
+		if !errors.Is(err, ErrDraining) || c.Counter != 1 {
+			t.Errorf("expected one run then ErrDraining, got %d runs and %v", c.Counter, err)
+		}
+	})
+
+	t.Run("CancelledBeforeStart", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		runStepTest(t, func(_ context.Context, c *CountingFlow) error {
+			return Reconcile(Increment(1), ReconcileOptions{})(ctx, c)
+		}, 0, isNil)
+	})
+}
@@ -4,7 +4,8 @@ package flow
 
 import (
 	"context"
-	"math/rand/v2"
+	"errors"
+	"fmt"
 	"time"
 )
 
@@ -68,7 +69,9 @@ func WithPercentageJitter(percent float64) BackoffOption {
 // than 30 seconds, even if the exponential calculation would produce a larger value.
 //
 // The cap is applied after jitter is calculated, ensuring the final delay
-// (including jitter) never exceeds the maximum.
+// (including jitter) never exceeds the maximum. It also bounds delays requested
+// by errors (see [RetryAfter]), so a misbehaving server cannot stall a retry
+// loop indefinitely.
 //
 // Applies to both [FixedBackoff] and [ExponentialBackoff].
 func WithMaxDelay(max time.Duration) BackoffOption {
@@ -92,10 +95,11 @@ func WithMultiplier(m float64) BackoffOption {
 
 // applyJitter applies jitter to a delay based on the configuration.
 //
-// Uses math/rand/v2, which is sufficient for backoff jitter as it uses the
-// ChaCha8 algorithm and is auto-seeded with 256 bits of OS entropy. Users
-// requiring additional security can seed the global generator themselves.
-func applyJitter(delay time.Duration, cfg *backoffConfig) time.Duration {
+// Uses math/rand/v2 by default, which is sufficient for backoff jitter as it
+// uses the ChaCha8 algorithm and is auto-seeded with 256 bits of OS entropy.
+// Users requiring additional security or reproducibility can install their
+// own generator with [WithRand].
+func applyJitter(delay time.Duration, cfg *backoffConfig, rng random) time.Duration {
 	if cfg.fullJitter {
 		// Full jitter: random value between 0 and delay
 		if delay <= 0 {
@@ -103,8 +107,7 @@ func applyJitter(delay time.Duration, cfg *backoffConfig) time.Duration {
 			// always produce positive delays in normal operation.
 			return 0
 		}
-		// #nosec G404 -- see doc comment for rationale
-		return time.Duration(rand.Int64N(int64(delay) + 1))
+		return time.Duration(rng.Int64N(int64(delay) + 1))
 	}
 	if cfg.percentJitter > 0 {
 		// Percentage jitter: delay ± (delay * percent)
@@ -114,8 +117,7 @@ func applyJitter(delay time.Duration, cfg *backoffConfig) time.Duration {
 			return 0
 		}
 		jitterRange := float64(delay) * cfg.percentJitter
-		// #nosec G404 -- see doc comment for rationale
-		jitterAmount := (rand.Float64() * 2 * jitterRange) - jitterRange
+		jitterAmount := (rng.Float64() * 2 * jitterRange) - jitterRange
 		result := float64(delay) + jitterAmount
 		if result < 0 {
 			// This branch cannot easily be tested since it would require
@@ -127,43 +129,456 @@ func applyJitter(delay time.Duration, cfg *backoffConfig) time.Duration {
 	return delay
 }
 
+// finalDelay determines how long to wait before the next attempt.
+//
+// A delay requested by err takes the place of the computed delay, and
+// jitter is applied only to computed delays. The result is capped by
+// [WithMaxDelay] in both cases.
+func (c *backoffConfig) finalDelay(ctx context.Context, delay time.Duration, err error) time.Duration {
+	if requested, ok := RetryAfter(err); ok {
+		delay = requested
+	} else {
+		delay = applyJitter(delay, c, randFrom(ctx))
+	}
+	if c.maxDelay > 0 && delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+	return delay
+}
+
+// Reasons recorded in the "reason" attribute of the "backoff" trace events of
+// [FixedBackoff] and [ExponentialBackoff].
+const (
+	// BackoffFixed means the delay was the fixed delay of FixedBackoff.
+	BackoffFixed = "fixed"
+
+	// BackoffExponential means the delay was calculated by
+	// ExponentialBackoff.
+	BackoffExponential = "exponential"
+
+	// BackoffRetryAfter means the failed attempt's error requested the
+	// delay (see [RetryAfter]).
+	BackoffRetryAfter = "retry-after"
+)
+
+// waitBackoff waits before a retry, returning false if the context is
+// cancelled first. The delay computed by the predicate, described by kind, is
+// adjusted by finalDelay.
+//
+// Within a Retry, the retry is first withdrawn from the retry budget, so that
+// no time is spent waiting for a retry the budget refuses; waitBackoff
+// returns false right away if the budget is exhausted.
+//
+// When tracing is enabled, the wait is recorded as a "backoff" event
+// annotated with the delay and the reason for it.
+func (c *backoffConfig) waitBackoff(ctx context.Context, delay time.Duration, err error, kind string) bool {
+	trace := getTrace(ctx)
+	if decision := getRetryDecision(ctx); decision != nil {
+		if !decision.withdraw(ctx) {
+			return false
+		}
+		trace = decision.trace
+	}
+	delay = c.finalDelay(ctx, delay, err)
+	if trace == nil {
+		return sleep(ctx, delay) == nil
+	}
+	reason := kind
+	if _, ok := RetryAfter(err); ok {
+		reason = BackoffRetryAfter
+	}
+	waitErr := trace.run(ctx, KindBackoff, func(ctx context.Context) error {
+		Annotate(ctx, "delay", delay)
+		Annotate(ctx, "reason", reason)
+		return sleep(ctx, delay)
+	})
+	return waitErr == nil
+}
+
+// RetryAfter returns the delay requested by an error in err's chain.
+//
+// An error requests a delay by implementing:
+//
+//	interface{ RetryAfter() time.Duration }
+//
+// This is typically used to surface a server's Retry-After header, letting
+// [FixedBackoff] and [ExponentialBackoff] wait as long as the server asked
+// rather than their own computed delay. Negative delays are treated as zero.
+//
+// The second result is false if no error in the chain requests a delay.
+func RetryAfter(err error) (time.Duration, bool) {
+	var hint interface{ RetryAfter() time.Duration }
+	if !errors.As(err, &hint) {
+		return 0, false
+	}
+	return max(hint.RetryAfter(), 0), true
+}
+
+// RetryOptions specifies how attempts are run by [RetryWith] and its
+// [Extract], [Transform], and [Consume] variants.
+type RetryOptions struct {
+	// PerAttemptTimeout gives each attempt its own deadline.
+	//
+	// Each attempt runs with a context derived from the parent that is
+	// cancelled after this duration, while the overall time budget still
+	// comes from the parent context. An attempt that fails because its own
+	// deadline expired is classified as transient (see [Transient]), even when
+	// it returns a bare [context.DeadlineExceeded]. If the parent context is
+	// done, the attempt is not retried.
+	//
+	// Values less than or equal to zero indicate no per-attempt timeout.
+	PerAttemptTimeout time.Duration
+}
+
 // Retry executes a step and retries it on failure based on the given
 // predicates.
 //
 // All predicates must return true for a retry to occur. If any predicate
 // returns false, the last error is returned immediately.
 //
+// If the step returns a permanent error (see [Permanent]), or if the context
+// is done, the error is returned immediately without consulting the
+// predicates. If the error requests a delay (see [RetryAfter]), the backoff
+// predicates wait for that delay instead of their own.
+//
+// If a [RetryBudget] is installed (see [WithRetryBudget]), each retry that the
+// predicates allow must also be allowed by the budget; otherwise an error
+// wrapping [ErrRetryBudgetExhausted] is returned. Likewise, no retry is
+// started once the workflow is draining (see [Drain]); an error wrapping
+// [ErrDraining] and the last error is returned instead.
+//
 // If no predicates are provided, this defaults to retrying up to 3 times with
 // exponential backoff starting at 100ms and full jitter to prevent thundering
 // herd problems.
+//
+// When tracing is enabled (see [Traced]), Retry records a "retry" event with
+// an "attempt" event for each attempt, holding the attempt's duration and
+// error, and a "backoff" event for each wait of [FixedBackoff] or
+// [ExponentialBackoff], annotated with the "delay" and the "reason" for it.
+// These events are not steps (see [TraceEvent.Kind]): they have the path of
+// the step being retried, do not change the paths of the steps within them
+// (see [Names]), and are not counted in the totals of the trace. Failed steps
+// of attempts that were retried have [StatusRetried].
+//
+// Retry is the same as [RetryWith] with the default [RetryOptions].
 func Retry[T any](
 	step Step[T],
 	predicates ...RetryPredicate,
 ) Step[T] {
-	// set defaults if no predicates provided
-	if len(predicates) == 0 {
-		predicates = []RetryPredicate{
-			UpTo(3),
-			ExponentialBackoff(100*time.Millisecond, WithFullJitter()),
-		}
-	}
+	return RetryWith(RetryOptions{}, step, predicates...)
+}
+
+// RetryWith executes a step and retries it on failure based on the given
+// predicates, with custom options.
+//
+// Example:
+//
+//	// Give each call 5 seconds, retrying timeouts up to 3 times
+//	flow.RetryWith(
+//	    flow.RetryOptions{PerAttemptTimeout: 5 * time.Second},
+//	    CallExternalAPI(),
+//	    flow.UpTo(3),
+//	    flow.ExponentialBackoff(100*time.Millisecond),
+//	)
+//
+// See [Retry] for details on how predicates are evaluated.
+func RetryWith[T any](
+	opts RetryOptions,
+	step Step[T],
+	predicates ...RetryPredicate,
+) Step[T] {
+	predicates = defaultPredicates(predicates)
 	return func(ctx context.Context, t T) error {
-		attempts := 0
-		for {
-			err := step(ctx, t)
-			if err == nil {
-				return nil
+		return runRetry(ctx, opts, predicates, true, func(ctx context.Context) error {
+			return step(ctx, t)
+		})
+	}
+}
+
+// RetryExtract executes an [Extract] and retries it on failure based on the
+// given predicates.
+//
+// This avoids capturing the result of a retried step in a variable:
+//
+//	flow.With(
+//	    flow.RetryExtract(FetchInventory, flow.UpTo(5)),
+//	    SaveInventory,
+//	)
+//
+// See [Retry] for details on how predicates are evaluated.
+func RetryExtract[T, U any](
+	extract Extract[T, U],
+	predicates ...RetryPredicate,
+) Extract[T, U] {
+	return RetryExtractWith(RetryOptions{}, extract, predicates...)
+}
+
+// RetryExtractWith is like [RetryExtract] with custom options.
+func RetryExtractWith[T, U any](
+	opts RetryOptions,
+	extract Extract[T, U],
+	predicates ...RetryPredicate,
+) Extract[T, U] {
+	predicates = defaultPredicates(predicates)
+	return func(ctx context.Context, t T) (U, error) {
+		var u U
+		err := runRetry(ctx, opts, predicates, true, func(ctx context.Context) error {
+			var err error
+			u, err = extract(ctx, t)
+			return err
+		})
+		return u, err
+	}
+}
+
+// RetryTransform executes a [Transform] and retries it on failure based on the
+// given predicates.
+//
+// See [Retry] for details on how predicates are evaluated.
+func RetryTransform[T, In, Out any](
+	transform Transform[T, In, Out],
+	predicates ...RetryPredicate,
+) Transform[T, In, Out] {
+	return RetryTransformWith(RetryOptions{}, transform, predicates...)
+}
+
+// RetryTransformWith is like [RetryTransform] with custom options.
+func RetryTransformWith[T, In, Out any](
+	opts RetryOptions,
+	transform Transform[T, In, Out],
+	predicates ...RetryPredicate,
+) Transform[T, In, Out] {
+	predicates = defaultPredicates(predicates)
+	return func(ctx context.Context, t T, in In) (Out, error) {
+		var out Out
+		err := runRetry(ctx, opts, predicates, true, func(ctx context.Context) error {
+			var err error
+			out, err = transform(ctx, t, in)
+			return err
+		})
+		return out, err
+	}
+}
+
+// RetryConsume executes a [Consume] and retries it on failure based on the
+// given predicates.
+//
+// See [Retry] for details on how predicates are evaluated.
+func RetryConsume[T, U any](
+	consume Consume[T, U],
+	predicates ...RetryPredicate,
+) Consume[T, U] {
+	return RetryConsumeWith(RetryOptions{}, consume, predicates...)
+}
+
+// RetryConsumeWith is like [RetryConsume] with custom options.
+func RetryConsumeWith[T, U any](
+	opts RetryOptions,
+	consume Consume[T, U],
+	predicates ...RetryPredicate,
+) Consume[T, U] {
+	predicates = defaultPredicates(predicates)
+	return func(ctx context.Context, t T, u U) error {
+		return runRetry(ctx, opts, predicates, true, func(ctx context.Context) error {
+			return consume(ctx, t, u)
+		})
+	}
+}
+
+// defaultPredicates returns the default retry policy if no predicates are
+// provided, and the predicates unchanged otherwise.
+func defaultPredicates(predicates []RetryPredicate) []RetryPredicate {
+	if len(predicates) > 0 {
+		return predicates
+	}
+	return []RetryPredicate{
+		UpTo(3),
+		ExponentialBackoff(100*time.Millisecond, WithFullJitter()),
+	}
+}
+
+// runRetry is the retry loop shared by [RetryWith] and its variants.
+//
+// When tracing is enabled and traced is true, the loop is recorded as a
+// "retry" event, with an "attempt" event for each attempt and a "backoff"
+// event for each wait (see [ExponentialBackoff] and [FixedBackoff]).
+func runRetry(
+	ctx context.Context,
+	opts RetryOptions,
+	predicates []RetryPredicate,
+	traced bool,
+	attempt func(context.Context) error,
+) error {
+	budget := getRetryBudget(ctx)
+	var trace *trace
+	if traced {
+		trace = getTrace(ctx)
+	}
+	if budget != nil {
+		budget.deposit(ClockFrom(ctx).Now())
+	}
+	if trace == nil {
+		return retryLoop(ctx, nil, budget, opts, predicates, attempt)
+	}
+	return trace.run(ctx, KindRetry, func(ctx context.Context) error {
+		return retryLoop(ctx, trace, budget, opts, predicates, attempt)
+	})
+}
+
+// retryDecision is the decision whether to retry after a failed attempt,
+// shared with the backoff predicates through their context.
+type retryDecision struct {
+	// trace records the backoff, or nil if the retry is not traced.
+	trace *trace
+
+	// budget is the retry budget, or nil if there is none.
+	budget *RetryBudget
+
+	// withdrawn reports whether the retry was withdrawn from the budget,
+	// at withdrawnAt.
+	withdrawn   bool
+	withdrawnAt time.Time
+
+	// exhausted reports whether the budget refused the retry.
+	exhausted bool
+}
+
+// withdraw withdraws the retry from the budget, unless it already was,
+// returning false if the budget is exhausted.
+func (d *retryDecision) withdraw(ctx context.Context) bool {
+	if d.budget == nil || d.withdrawn {
+		return true
+	}
+	if d.exhausted {
+		return false
+	}
+	now := ClockFrom(ctx).Now()
+	if !d.budget.withdraw(now) {
+		d.exhausted = true
+		return false
+	}
+	d.withdrawn, d.withdrawnAt = true, now
+	return true
+}
+
+// refund returns the withdrawn retry to the budget if it is not performed.
+func (d *retryDecision) refund() {
+	if d.withdrawn {
+		d.budget.refund(d.withdrawnAt)
+		d.withdrawn = false
+	}
+}
+
+// getRetryDecision retrieves the retry decision being made from the
+// context, or nil if there is none.
+func getRetryDecision(ctx context.Context) *retryDecision {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if !ok {
+		return nil
+	}
+	return f.retryDecision
+}
+
+// retryLoop runs attempts until one succeeds or the predicates, the retry
+// budget, or the workflow stop it. trace is nil if the loop is not traced.
+func retryLoop(
+	ctx context.Context,
+	trace *trace,
+	budget *RetryBudget,
+	opts RetryOptions,
+	predicates []RetryPredicate,
+	attempt func(context.Context) error,
+) error {
+	attempts := 0
+	var scope *retryAttempt
+	if trace != nil {
+		// The last attempt is not run again, however the loop ends.
+		defer func() {
+			trace.settleAttempt(scope)
+		}()
+	}
+	for {
+		if trace != nil {
+			scope = &retryAttempt{parent: currentEvent(ctx)}
+		}
+		attemptCtx := withAttempt(ctx, attempts+1, scope)
+		var err error
+		if trace != nil {
+			err = trace.run(attemptCtx, KindAttempt, func(ctx context.Context) error {
+				// Collect the steps directly within the attempt.
+				scope.parent = currentEvent(ctx)
+				return runAttempt(ctx, opts, attempt)
+			})
+		} else {
+			err = runAttempt(attemptCtx, opts, attempt)
+		}
+		if err == nil {
+			return nil
+		}
+		attempts++
+		if IsPermanent(err) || ctx.Err() != nil {
+			return err
+		}
+		if stop := checkBoundary(ctx); stop != nil {
+			return fmt.Errorf("%w: %w", stop, err)
+		}
+		// Backoff predicates withdraw the retry from the budget before
+		// waiting; otherwise it is withdrawn once all predicates agree.
+		decision := &retryDecision{trace: trace, budget: budget}
+		decisionCtx := withRetryDecision(ctx, decision)
+		retry := true
+		for _, predicate := range predicates {
+			if !predicate(decisionCtx, attempts, err) {
+				retry = false
+				break
 			}
-			attempts++
-			for _, predicate := range predicates {
-				if !predicate(ctx, attempts, err) {
-					return err
-				}
+		}
+		if retry {
+			retry = decision.withdraw(ctx)
+		}
+		if decision.exhausted {
+			if trace != nil {
+				trace.recordRetry(false, scope)
 			}
+			return fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, err)
+		}
+		if !retry {
+			decision.refund()
+			return err
+		}
+		// The workflow may have been paused or started draining during a
+		// backoff.
+		if stop := checkBoundary(ctx); stop != nil {
+			decision.refund()
+			return fmt.Errorf("%w: %w", stop, err)
+		}
+		if trace != nil {
+			trace.recordRetry(true, scope)
 		}
 	}
 }
 
+// runAttempt runs a single attempt, applying the per-attempt timeout if one
+// is configured.
+func runAttempt(
+	ctx context.Context,
+	opts RetryOptions,
+	attempt func(context.Context) error,
+) error {
+	if opts.PerAttemptTimeout <= 0 {
+		return attempt(ctx)
+	}
+	cause := &DeadlineError{Path: Names(ctx), Timeout: opts.PerAttemptTimeout}
+	attemptCtx, cancel := withTimeout(ctx, opts.PerAttemptTimeout, cause)
+	defer cancel()
+	err := withCause(attemptCtx, attempt(attemptCtx), cause)
+	if err != nil && ctx.Err() == nil && attemptCtx.Err() != nil && !IsTransient(err) {
+		// The attempt ran out of time, but the parent still has budget left.
+		err = Transient(err)
+	}
+	return err
+}
+
 // UpTo limits retries to a maximum number of attempts.
 //
 // The predicate returns true if attempts < maxAttempts, allowing retries
@@ -179,6 +594,9 @@ func UpTo(maxAttempts int) RetryPredicate {
 // The delay is applied before each retry. If the context is cancelled during
 // the wait, the predicate returns false and the retry is aborted.
 //
+// If the error requests a delay (see [RetryAfter]), that delay is used instead
+// of the fixed one, still subject to [WithMaxDelay].
+//
 // Options:
 //   - [WithFullJitter] randomizes delay between 0 and the fixed duration
 //   - [WithPercentageJitter] adds ±N% randomness to the fixed duration
@@ -192,26 +610,8 @@ func FixedBackoff(delay time.Duration, opts ...BackoffOption) RetryPredicate {
 		opt(&cfg)
 	}
 
-	return func(ctx context.Context, _ int, _ error) bool {
-		actualDelay := applyJitter(delay, &cfg)
-
-		// Apply max delay cap if configured
-		if cfg.maxDelay > 0 && actualDelay > cfg.maxDelay {
-			// This branch cannot easily be tested since it requires jitter
-			// to push the delay above the maxDelay cap, which is unlikely
-			// with FixedBackoff and requires specific random values.
-			actualDelay = cfg.maxDelay
-		}
-
-		timer := time.NewTimer(actualDelay)
-		defer timer.Stop()
-
-		select {
-		case <-ctx.Done():
-			return false
-		case <-timer.C:
-			return true
-		}
+	return func(ctx context.Context, _ int, err error) bool {
+		return cfg.waitBackoff(ctx, delay, err, BackoffFixed)
 	}
 }
 
@@ -228,6 +628,9 @@ func FixedBackoff(delay time.Duration, opts ...BackoffOption) RetryPredicate {
 //
 // If the context is cancelled during the wait, the predicate returns false.
 //
+// If the error requests a delay (see [RetryAfter]), that delay is used instead
+// of the calculated one, still subject to [WithMaxDelay].
+//
 // Options:
 //   - [WithFullJitter] randomizes delay between 0 and the calculated delay
 //   - [WithPercentageJitter] adds ±N% randomness to the calculated delay
@@ -241,57 +644,57 @@ func ExponentialBackoff(base time.Duration, opts ...BackoffOption) RetryPredicat
 		opt(&cfg)
 	}
 
-	return func(ctx context.Context, attempts int, _ error) bool {
-		if attempts < 1 {
-			attempts = 1
-		}
+	return func(ctx context.Context, attempts int, err error) bool {
+		delay, _ := cfg.exponentialDelay(base, attempts)
+		return cfg.waitBackoff(ctx, delay, err, BackoffExponential)
+	}
+}
 
-		var delay time.Duration
-		if cfg.multiplier == 2.0 {
-			// Use bit-shifting for the common case (multiplier = 2.0)
-			// #nosec G115 -- attempts >= 1, conversion is safe
-			shift := uint(attempts) - 1
-			if shift > 62 {
-				shift = 62
-			}
-			delay = base * time.Duration(1<<shift)
-			if delay.Seconds() == 0 {
-				delay = base
-			}
-		} else {
-			// Use power calculation for custom multipliers
-			multiplier := cfg.multiplier
-			for i := 1; i < attempts; i++ {
-				base = time.Duration(float64(base) * multiplier)
-				// Prevent overflow
-				if base.Seconds() == 0 || base < 0 {
-					// This branch cannot easily be tested since it requires
-					// extreme multipliers or attempt counts to cause overflow.
-					base = time.Hour * 24 * 365 // cap at 1 year
-					break
-				}
-			}
+// exponentialDelay calculates the delay before the retry following the given
+// number of attempts, before jitter is applied. It returns false if the delay
+// overflows, in which case the delay is the maximum delay if one is set, or
+// else the base delay (for the default multiplier) or a year.
+func (c *backoffConfig) exponentialDelay(base time.Duration, attempts int) (time.Duration, bool) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	if c.multiplier == 2.0 {
+		// Use bit-shifting for the common case (multiplier = 2.0)
+		// #nosec G115 -- attempts >= 1, conversion is safe
+		shift := uint(attempts) - 1
+		if shift > 62 {
+			shift = 62
+		}
+		delay := base * time.Duration(1<<shift)
+		if delay < 0 || delay/time.Duration(1<<shift) != base {
+			return c.overflowDelay(base), false
+		}
+		if delay.Seconds() == 0 {
 			delay = base
 		}
+		return delay, true
+	}
 
-		// Apply jitter
-		delay = applyJitter(delay, &cfg)
-
-		// Apply max delay cap if configured
-		if cfg.maxDelay > 0 && delay > cfg.maxDelay {
-			delay = cfg.maxDelay
+	// Use power calculation for custom multipliers
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay = time.Duration(float64(delay) * c.multiplier)
+		// Prevent overflow
+		if delay.Seconds() == 0 || delay < 0 {
+			return c.overflowDelay(time.Hour * 24 * 365), false
 		}
+	}
+	return delay, true
+}
 
-		timer := time.NewTimer(delay)
-		defer timer.Stop()
-
-		select {
-		case <-ctx.Done():
-			return false
-		case <-timer.C:
-			return true
-		}
+// overflowDelay is the delay used when an exponential delay overflows: the
+// maximum delay if one is set, or else fallback.
+func (c *backoffConfig) overflowDelay(fallback time.Duration) time.Duration {
+	if c.maxDelay > 0 {
+		return c.maxDelay
 	}
+	return fallback
 }
 
 // OnlyIf conditionally retries based on the error.
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRetryBudgetExhausted indicates that a retry was refused because the
+// workflow's [RetryBudget] has been used up.
+//
+// The error returned by [Retry] wraps both this sentinel and the error from
+// the last attempt, so either can be detected with [errors.Is].
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// retryBudgetWindow is the number of one-second buckets over which a
+// [RetryBudget] tracks calls and retries.
+const retryBudgetWindow = 10
+
+// RetryBudget limits the number of retries across an entire workflow.
+//
+// With [Retry] nested at several levels, or used inside a [ForEach] over many
+// items, a single outage can multiply into thousands of attempts. A budget
+// caps retries as a fraction of calls, so a healthy workflow can retry freely
+// while a failing one quickly stops hammering its dependencies.
+//
+// Each call to a retried step deposits credit, and each retry withdraws it.
+// Calls and retries are counted over a sliding ten-second window.
+//
+// A RetryBudget is safe for concurrent use and is installed for a workflow
+// with [WithRetryBudget].
+type RetryBudget struct {
+	ratio        float64
+	minPerSecond int
+
+	mu      sync.Mutex
+	buckets [retryBudgetWindow]retryBudgetBucket
+}
+
+// retryBudgetBucket counts calls and retries during one second.
+type retryBudgetBucket struct {
+	second  int64
+	calls   int
+	retries int
+}
+
+// NewRetryBudget creates a budget allowing retries up to ratio times the
+// number of calls, plus minPerSecond retries per second regardless of the
+// number of calls.
+//
+// For example, NewRetryBudget(0.1, 5) allows retries to make up 10% of calls,
+// while always permitting a trickle of 5 retries per second so that workflows
+// with few calls can still retry.
+func NewRetryBudget(ratio float64, minPerSecond int) *RetryBudget {
+	return &RetryBudget{
+		ratio:        max(ratio, 0),
+		minPerSecond: max(minPerSecond, 0),
+	}
+}
+
+// WithRetryBudget configures a [Step] to share a [RetryBudget] among all
+// [Retry] combinators (and variants) nested within it.
+//
+// Once the budget is exhausted, retries fail with [ErrRetryBudgetExhausted]
+// instead of waiting and trying again. Budget consumption is recorded in
+// [Trace.TotalRetries] and [Trace.TotalRetriesRejected] when tracing.
+//
+// This is typically applied once at the root of a workflow.
+//
+// Example:
+//
+//	budget := flow.NewRetryBudget(0.1, 10)
+//	workflow := flow.WithRetryBudget(budget,
+//	    flow.InParallel(
+//	        flow.ForEach(GetItems, func(item Item) flow.Step[*State] {
+//	            return flow.Retry(ProcessItem(item))
+//	        }),
+//	    ),
+//	)
+func WithRetryBudget[T any](budget *RetryBudget, step Step[T]) Step[T] {
+	return func(ctx context.Context, t T) error {
+		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
+		f2 := newFlowCtx(ctx, f)
+		f2.retryBudget = budget
+		return step(f2, t)
+	}
+}
+
+// getRetryBudget retrieves the retry budget from the context, or nil if not present.
+func getRetryBudget(ctx context.Context) *RetryBudget {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if !ok {
+		return nil
+	}
+	return f.retryBudget
+}
+
+// deposit records a call to a retried step.
+func (b *RetryBudget) deposit(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bucket(now).calls++
+}
+
+// withdraw records a retry if the budget allows it, returning false if the
+// budget is exhausted.
+func (b *RetryBudget) withdraw(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.bucket(now)
+	oldest := now.Unix() - retryBudgetWindow
+	calls, retries := 0, 0
+	for _, bucket := range b.buckets {
+		if bucket.second > oldest {
+			calls += bucket.calls
+			retries += bucket.retries
+		}
+	}
+
+	allowed := b.ratio*float64(calls) + float64(b.minPerSecond*retryBudgetWindow)
+	if float64(retries) >= allowed {
+		return false
+	}
+	current.retries++
+	return true
+}
+
+// refund returns a retry withdrawn at the given time that was not performed,
+// unless its bucket has since been reused.
+func (b *RetryBudget) refund(at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket := &b.buckets[at.Unix()%retryBudgetWindow]
+	if bucket.second == at.Unix() && bucket.retries > 0 {
+		bucket.retries--
+	}
+}
+
+// bucket returns the bucket for the second containing now, resetting it if
+// it last held counts from an earlier window.
+func (b *RetryBudget) bucket(now time.Time) *retryBudgetBucket {
+	second := now.Unix()
+	bucket := &b.buckets[second%retryBudgetWindow]
+	if bucket.second != second {
+		*bucket = retryBudgetBucket{second: second}
+	}
+	return bucket
+}
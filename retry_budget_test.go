@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryBudget(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RatioOfCalls", func(t *testing.T) {
+		t.Parallel()
+		budget := NewRetryBudget(0.5, 0)
+		now := time.Unix(1000, 0)
+		for range 4 {
+			budget.deposit(now)
+		}
+		// 4 calls at 50% allows 2 retries
+		for i := range 2 {
+			if !budget.withdraw(now) {
+				t.Fatalf("retry %d: expected budget to allow retry", i)
+			}
+		}
+		if budget.withdraw(now) {
+			t.Error("expected budget to be exhausted")
+		}
+	})
+
+	t.Run("MinimumPerSecond", func(t *testing.T) {
+		t.Parallel()
+		budget := NewRetryBudget(0, 1)
+		now := time.Unix(1000, 0)
+		// 1 per second over a 10 second window
+		for i := range retryBudgetWindow {
+			if !budget.withdraw(now) {
+				t.Fatalf("retry %d: expected budget to allow retry", i)
+			}
+		}
+		if budget.withdraw(now) {
+			t.Error("expected budget to be exhausted")
+		}
+	})
+
+	t.Run("WindowSlides", func(t *testing.T) {
+		t.Parallel()
+		budget := NewRetryBudget(1, 0)
+		start := time.Unix(1000, 0)
+		budget.deposit(start)
+		if !budget.withdraw(start) {
+			t.Fatal("expected budget to allow retry")
+		}
+		if budget.withdraw(start.Add(time.Second)) {
+			t.Fatal("expected budget to be exhausted")
+		}
+		// Once the window has passed, new calls earn fresh credit.
+		later := start.Add(retryBudgetWindow * time.Second)
+		budget.deposit(later)
+		if !budget.withdraw(later) {
+			t.Error("expected budget to allow retry after window slides")
+		}
+	})
+
+	t.Run("RetryFailsWhenExhausted", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		step := WithRetryBudget(
+			NewRetryBudget(0, 0),
+			Retry(IncrementAndFail(error1), UpTo(5)),
+		)
+		err := step(t.Context(), &c)
+		if !errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Errorf("expected %v, got %v", ErrRetryBudgetExhausted, err)
+		}
+		if !errors.Is(err, error1) {
+			t.Errorf("expected %v, got %v", error1, err)
+		}
+		if c.Counter != 1 {
+			t.Errorf("expected counter 1, got %d", c.Counter)
+		}
+	})
+
+	t.Run("NoBackoffWhenExhausted", func(t *testing.T) {
+		t.Parallel()
+		// The budget is checked before the backoff, so the hour-long wait
+		// never starts.
+		step := WithRetryBudget(
+			NewRetryBudget(0, 0),
+			Retry(IncrementAndFail(error1), UpTo(5), FixedBackoff(time.Hour)),
+		)
+		trace, err := Traced(step)(t.Context(), &CountingFlow{})
+		if !errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Errorf("expected %v, got %v", ErrRetryBudgetExhausted, err)
+		}
+		if trace.FindEvent(HasKind(KindBackoff)) != nil || trace.TotalRetriesRejected != 1 {
+			t.Errorf("expected a rejected retry without a backoff, got %+v", trace)
+		}
+	})
+
+	t.Run("RefundedWhenNotRetried", func(t *testing.T) {
+		t.Parallel()
+		// A predicate after the backoff refuses the retry, so the retry
+		// withdrawn before the backoff is returned to the budget.
+		budget := NewRetryBudget(0, 1)
+		refuse := func(context.Context, int, error) bool { return false }
+		step := WithRetryBudget(budget, Retry(IncrementAndFail(error1), FixedBackoff(0), refuse))
+		if err := step(t.Context(), &CountingFlow{}); !errors.Is(err, error1) || errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Errorf("expected %v, got %v", error1, err)
+		}
+		now := time.Now()
+		for i := range retryBudgetWindow {
+			if !budget.withdraw(now) {
+				t.Fatalf("retry %d: expected budget to allow retry", i)
+			}
+		}
+	})
+
+	t.Run("SharedAcrossNestedRetries", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		// 10 calls at 20% allows 2 retries in total, far fewer than the
+		// 10 × 4 = 40 retries the predicates would otherwise allow.
+		items := make([]int, 10)
+		step := WithRetryBudget(
+			NewRetryBudget(0.2, 0),
+			InSerialWith(
+				Options{JoinErrors: true},
+				ForEach(
+					Value[*CountingFlow](items),
+					func(int) Step[*CountingFlow] {
+						return Retry(IncrementAndFail(error1), UpTo(5))
+					},
+				),
+			),
+		)
+		err := step(t.Context(), &c)
+		if !errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Errorf("expected %v, got %v", ErrRetryBudgetExhausted, err)
+		}
+		if c.Counter != 12 {
+			t.Errorf("expected counter 12, got %d", c.Counter)
+		}
+	})
+
+	t.Run("RecordedInTrace", func(t *testing.T) {
+		t.Parallel()
+		step := WithRetryBudget(
+			NewRetryBudget(0, 1),
+			Named("call", Retry(FailUntilCount(100), UpTo(20))),
+		)
+		trace, err := Traced(step)(t.Context(), &CountingFlow{})
+		if !errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Errorf("expected %v, got %v", ErrRetryBudgetExhausted, err)
+		}
+		if trace.TotalRetries != retryBudgetWindow {
+			t.Errorf("expected %d retries, got %d", retryBudgetWindow, trace.TotalRetries)
+		}
+		if trace.TotalRetriesRejected != 1 {
+			t.Errorf("expected 1 rejected retry, got %d", trace.TotalRetriesRejected)
+		}
+		if trace.TotalErrors != 1 {
+			t.Errorf("expected 1 error, got %d", trace.TotalErrors)
+		}
+	})
+
+	t.Run("RetriesTracedWithoutBudget", func(t *testing.T) {
+		t.Parallel()
+		trace, err := Traced(Retry(FailUntilCount(3), UpTo(5)))(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if trace.TotalRetries != 2 {
+			t.Errorf("expected 2 retries, got %d", trace.TotalRetries)
+		}
+		if trace.TotalRetriesRejected != 0 {
+			t.Errorf("expected no rejected retries, got %d", trace.TotalRetriesRejected)
+		}
+	})
+}
@@ -3,8 +3,12 @@
 package flow
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -71,6 +75,37 @@ func TestRetry(t *testing.T) {
 			expectedCounter: 1,
 			validator:       isNotNil,
 		},
+		{
+			name: "PermanentStopsImmediately",
+			step: Retry(
+				IncrementAndFail(Permanent(error1)),
+				UpTo(3),
+			),
+			// permanent errors are never retried
+			expectedCounter: 1,
+			validator:       all(matches(error1), isPermanent),
+		},
+		{
+			name: "PermanentOverridesPredicates",
+			step: Retry(
+				Named("step", IncrementAndFail(Permanent(error1))),
+				OnlyIf(func(err error) bool { return true }),
+				UpTo(3),
+			),
+			// detected through the StepError wrapper
+			expectedCounter: 1,
+			validator:       all(matches(error1), isPermanent),
+		},
+		{
+			name: "TransientRetried",
+			step: Retry(
+				IncrementAndFail(Transient(error1)),
+				OnlyIf(IsTransient),
+				UpTo(3),
+			),
+			expectedCounter: 3,
+			validator:       matches(error1),
+		},
 		{
 			name: "ComposedPredicates",
 			step: Retry(
@@ -365,6 +400,38 @@ func TestBackoffOptions(t *testing.T) {
 		}
 	})
 
+	t.Run("MultiplierDoesNotCompound", func(t *testing.T) {
+		t.Parallel()
+		cfg := backoffConfig{multiplier: 1.5}
+		// Repeated calls, as by separate retry loops, give the same schedule.
+		for range 2 {
+			for attempts, want := range []time.Duration{100, 100, 150, 225} {
+				got, _ := cfg.exponentialDelay(100*time.Millisecond, attempts)
+				if got != want*time.Millisecond {
+					t.Errorf("attempt %d: got %v, want %v", attempts, got, want*time.Millisecond)
+				}
+			}
+		}
+	})
+
+	t.Run("OverflowUsesMaxDelay", func(t *testing.T) {
+		t.Parallel()
+		for _, cfg := range []backoffConfig{
+			{multiplier: 2.0, maxDelay: time.Minute},
+			{multiplier: 1.5, maxDelay: time.Minute},
+		} {
+			if _, ok := cfg.exponentialDelay(time.Second, 30); !ok {
+				t.Errorf("multiplier %v: expected no overflow after 30 attempts", cfg.multiplier)
+			}
+			for _, attempts := range []int{63, 1000} {
+				got, ok := cfg.exponentialDelay(time.Second, attempts)
+				if ok || got != time.Minute {
+					t.Errorf("multiplier %v, attempt %d: got %v, want the max delay", cfg.multiplier, attempts, got)
+				}
+			}
+		}
+	})
+
 	t.Run("CombinedOptions", func(t *testing.T) {
 		t.Parallel()
 		var c CountingFlow
@@ -397,3 +464,345 @@ func TestBackoffOptions(t *testing.T) {
 	})
 
 }
+
+// retryAfterError is an error that requests a specific retry delay.
+type retryAfterError struct {
+	delay time.Duration
+}
+
+func (e retryAfterError) Error() string {
+	return "try again later"
+}
+
+func (e retryAfterError) RetryAfter() time.Duration {
+	return e.delay
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Lookup", func(t *testing.T) {
+		t.Parallel()
+		if _, ok := RetryAfter(error1); ok {
+			t.Error("expected no delay for plain error")
+		}
+		err := &StepError{Path: []string{"call"}, Cause: retryAfterError{delay: time.Second}}
+		delay, ok := RetryAfter(err)
+		if !ok || delay != time.Second {
+			t.Errorf("got (%v, %v), want (1s, true)", delay, ok)
+		}
+		delay, ok = RetryAfter(retryAfterError{delay: -time.Second})
+		if !ok || delay != 0 {
+			t.Errorf("got (%v, %v), want (0s, true)", delay, ok)
+		}
+	})
+
+	t.Run("HonouredByBackoff", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		start := time.Now()
+		// The server asks for 100ms, overriding the 1ms computed backoff.
+		_ = Retry(
+			IncrementAndFail(retryAfterError{delay: 100 * time.Millisecond}),
+			UpTo(2),
+			ExponentialBackoff(time.Millisecond),
+		)(t.Context(), &c)
+		elapsed := time.Since(start)
+		if elapsed < 100*time.Millisecond {
+			t.Errorf("expected at least 100ms, got %v", elapsed)
+		}
+		if c.Counter != 2 {
+			t.Errorf("expected counter 2, got %d", c.Counter)
+		}
+	})
+
+	t.Run("BoundedByMaxDelay", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		start := time.Now()
+		// The server asks for an hour, but the cap keeps it to 50ms.
+		_ = Retry(
+			IncrementAndFail(retryAfterError{delay: time.Hour}),
+			UpTo(2),
+			FixedBackoff(time.Millisecond, WithMaxDelay(50*time.Millisecond)),
+		)(t.Context(), &c)
+		elapsed := time.Since(start)
+		if elapsed < 50*time.Millisecond {
+			t.Errorf("expected at least 50ms, got %v", elapsed)
+		}
+		if elapsed > time.Second {
+			t.Errorf("expected max delay to bound the wait, got %v", elapsed)
+		}
+		if c.Counter != 2 {
+			t.Errorf("expected counter 2, got %d", c.Counter)
+		}
+	})
+}
+
+func TestRetryVariants(t *testing.T) {
+	t.Parallel()
+
+	// failUntil returns an extract that fails until it has been called n times.
+	failUntil := func(n int64) Extract[*CountingFlow, int64] {
+		return func(_ context.Context, c *CountingFlow) (int64, error) {
+			current := atomic.AddInt64(&c.Counter, 1)
+			if current < n {
+				return 0, errorRetryable
+			}
+			return current, nil
+		}
+	}
+
+	t.Run("RetryExtract", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		got, err := RetryExtract(failUntil(3), UpTo(5))(t.Context(), &c)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != 3 {
+			t.Errorf("got %d, want 3", got)
+		}
+	})
+
+	t.Run("RetryExtractExhausted", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		_, err := RetryExtract(failUntil(10), UpTo(2))(t.Context(), &c)
+		if !errors.Is(err, errorRetryable) {
+			t.Errorf("expected %v, got %v", errorRetryable, err)
+		}
+		if c.Counter != 2 {
+			t.Errorf("expected counter 2, got %d", c.Counter)
+		}
+	})
+
+	t.Run("RetryTransform", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		double := func(ctx context.Context, c *CountingFlow, in int64) (int64, error) {
+			if atomic.AddInt64(&c.Counter, 1) < 2 {
+				return 0, errorRetryable
+			}
+			return in * 2, nil
+		}
+		got, err := RetryTransform(double, UpTo(3))(t.Context(), &c, 21)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != 42 {
+			t.Errorf("got %d, want 42", got)
+		}
+	})
+
+	t.Run("RetryConsume", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		add := func(ctx context.Context, c *CountingFlow, n int64) error {
+			if atomic.AddInt64(&c.Counter, n) < 3*n {
+				return errorRetryable
+			}
+			return nil
+		}
+		err := RetryConsume(add, UpTo(5))(t.Context(), &c, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c.Counter != 30 {
+			t.Errorf("expected counter 30, got %d", c.Counter)
+		}
+	})
+
+	t.Run("PermanentStopsExtract", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		extract := func(_ context.Context, c *CountingFlow) (int64, error) {
+			atomic.AddInt64(&c.Counter, 1)
+			return 0, Permanent(error1)
+		}
+		_, err := RetryExtract(extract, UpTo(5))(t.Context(), &c)
+		if !errors.Is(err, error1) {
+			t.Errorf("expected %v, got %v", error1, err)
+		}
+		if c.Counter != 1 {
+			t.Errorf("expected counter 1, got %d", c.Counter)
+		}
+	})
+}
+
+func TestPerAttemptTimeout(t *testing.T) {
+	t.Parallel()
+
+	// hang increments the counter, then waits for cancellation.
+	hang := func(ctx context.Context, c *CountingFlow) error {
+		atomic.AddInt64(&c.Counter, 1)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.Run("TimedOutAttemptsAreRetried", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		err := RetryWith(
+			RetryOptions{PerAttemptTimeout: 10 * time.Millisecond},
+			hang,
+			OnlyIf(IsTransient),
+			UpTo(3),
+		)(t.Context(), &c)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if !IsTransient(err) {
+			t.Errorf("expected timed-out attempt to be transient, got %v", err)
+		}
+		if c.Counter != 3 {
+			t.Errorf("expected counter 3, got %d", c.Counter)
+		}
+	})
+
+	t.Run("RecoversAfterTimeout", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		slowThenFast := func(ctx context.Context, c *CountingFlow) error {
+			if atomic.AddInt64(&c.Counter, 1) == 1 {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		}
+		err := RetryWith(
+			RetryOptions{PerAttemptTimeout: 10 * time.Millisecond},
+			slowThenFast,
+			UpTo(3),
+		)(t.Context(), &c)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if c.Counter != 2 {
+			t.Errorf("expected counter 2, got %d", c.Counter)
+		}
+	})
+
+	t.Run("ParentDeadlineStopsRetries", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		ctx, cancel := context.WithTimeout(t.Context(), 30*time.Millisecond)
+		defer cancel()
+		err := RetryWith(
+			RetryOptions{PerAttemptTimeout: time.Second},
+			hang,
+			UpTo(5),
+		)(ctx, &c)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if IsTransient(err) {
+			t.Errorf("expected parent timeout not to be transient, got %v", err)
+		}
+		if c.Counter != 1 {
+			t.Errorf("expected counter 1, got %d", c.Counter)
+		}
+	})
+
+	t.Run("ExtractVariant", func(t *testing.T) {
+		t.Parallel()
+		var c CountingFlow
+		extract := func(ctx context.Context, c *CountingFlow) (string, error) {
+			if atomic.AddInt64(&c.Counter, 1) == 1 {
+				<-ctx.Done()
+				return "", ctx.Err()
+			}
+			return "done", nil
+		}
+		got, err := RetryExtractWith(
+			RetryOptions{PerAttemptTimeout: 10 * time.Millisecond},
+			extract,
+			UpTo(2),
+		)(t.Context(), &c)
+		if err != nil || got != "done" {
+			t.Errorf("got (%q, %v), want (\"done\", nil)", got, err)
+		}
+	})
+}
+
+func TestRetryTrace(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Tree", func(t *testing.T) {
+		t.Parallel()
+		step := Named("deploy", Retry(
+			Named("call", IncrementAndFail(retryAfterError{delay: time.Millisecond})),
+			UpTo(2),
+			ExponentialBackoff(time.Hour),
+		))
+		trace, err := Traced(step)(t.Context(), &CountingFlow{})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		var buf bytes.Buffer
+		if _, err := trace.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for line := range strings.Lines(buf.String()) {
+			name, _, _ := strings.Cut(line, " (")
+			got = append(got, name)
+		}
+		want := []string{"deploy", "  retry", "    attempt", "      call", "    backoff", "    attempt", "      call"}
+		if !slices.Equal(got, want) {
+			t.Fatalf("unexpected tree:\n%s", buf.String())
+		}
+
+		// Retry events have the path of the retried step and do not change
+		// the paths of steps or their errors.
+		call := trace.FindEvent(NameMatches("call"))
+		if !slices.Equal(call.Names, []string{"deploy", "call"}) {
+			t.Errorf("unexpected call path %v", call.Names)
+		}
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || !slices.Equal(stepErr.Path, []string{"deploy"}) {
+			t.Errorf("unexpected error %v", err)
+		}
+
+		backoff := trace.FindEvent(HasKind(KindBackoff))
+		if backoff.Attrs["reason"] != BackoffRetryAfter || backoff.Attrs["delay"] != time.Millisecond {
+			t.Errorf("unexpected backoff attributes %v", backoff.Attrs)
+		}
+		if !slices.Equal(backoff.Names, []string{"deploy"}) {
+			t.Errorf("unexpected backoff path %v", backoff.Names)
+		}
+		if trace.TotalSteps != 3 || trace.TotalErrors != 3 {
+			t.Errorf("expected only the steps in the totals, got %d steps and %d errors", trace.TotalSteps, trace.TotalErrors)
+		}
+	})
+
+	t.Run("AnnotatesAttempt", func(t *testing.T) {
+		t.Parallel()
+		trace, err := Traced(Retry(func(ctx context.Context, c *CountingFlow) error {
+			Annotate(ctx, "calls", atomic.AddInt64(&c.Counter, 1))
+			return nil
+		}))(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := trace.FindEvent(HasAttr("calls")); e == nil || e.Kind != KindAttempt {
+			t.Errorf("expected the attempt to be annotated, got %+v", trace.Events)
+		}
+	})
+
+	t.Run("CancelledBackoff", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(t.Context())
+		cancelFirst := func(context.Context, int, error) bool {
+			cancel()
+			return true
+		}
+		step := Retry(IncrementAndFail(error1), cancelFirst, FixedBackoff(time.Hour))
+		trace, _ := Traced(step)(ctx, &CountingFlow{})
+		backoff := trace.FindEvent(HasKind(KindBackoff))
+		if backoff == nil || !strings.Contains(backoff.Error, context.Canceled.Error()) {
+			t.Errorf("expected a cancelled backoff, got %+v", trace.Events)
+		}
+	})
+}
@@ -5,6 +5,7 @@ package flow
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -25,6 +26,15 @@ type Options struct {
 	// If enabled, all steps are run to completion regardless of errors, and a
 	// combined `errors.Join` error of all non-nil errors is returned.
 	JoinErrors bool
+
+	// RecoverPanics converts panics in the steps into [RecoveredPanic]
+	// errors, which are then handled like any other error.
+	//
+	// The setting applies to all steps nested within the group. A panic is
+	// recovered by the innermost [Named] step (or the group itself, if there
+	// is none), so the RecoveredPanic records the step path at the point of
+	// the panic.
+	RecoverPanics bool
 }
 
 // Do executes multiple steps in order, one at a time.
@@ -61,9 +71,15 @@ func Do[T any](steps ...Step[T]) Step[T] {
 //	)
 func DoWith[T any](opts Options, steps ...Step[T]) Step[T] {
 	return func(ctx context.Context, t T) error {
+		if opts.RecoverPanics {
+			ctx = withRecoverPanics(ctx)
+		}
 		var errs []error
 		for _, step := range steps {
-			if err := step(ctx, t); err != nil {
+			if err := checkBoundary(ctx); err != nil {
+				return joinStop(errs, err)
+			}
+			if err := runStep(ctx, step, t); err != nil {
 				if !opts.JoinErrors {
 					return err
 				}
@@ -96,8 +112,14 @@ func InSerialWith[T any](
 	providers ...StepsProvider[T],
 ) Step[T] {
 	return func(ctx context.Context, t T) error {
+		if opts.RecoverPanics {
+			ctx = withRecoverPanics(ctx)
+		}
 		var errs []error
 		for _, provider := range providers {
+			if err := checkBoundary(ctx); err != nil {
+				return joinStop(errs, err)
+			}
 			steps, err := provider(ctx, t)
 			if err != nil {
 				if !opts.JoinErrors {
@@ -107,7 +129,10 @@ func InSerialWith[T any](
 				continue
 			}
 			for _, step := range steps {
-				if err := step(ctx, t); err != nil {
+				if err := checkBoundary(ctx); err != nil {
+					return joinStop(errs, err)
+				}
+				if err := runStep(ctx, step, t); err != nil {
 					if !opts.JoinErrors {
 						return err
 					}
@@ -130,11 +155,22 @@ type ParallelOptions struct {
 	//
 	// By default, when false, the first step that returns an error cancels
 	// the rest, and this first error is returned. (This is the behavior of
-	// the `errgroup` package.)
+	// the `errgroup` package.) The cancelled steps can retrieve the error
+	// that caused the cancellation with [context.Cause].
 	//
 	// If enabled, all steps are run to completion regardless of errors, and a
 	// combined `errors.Join` error of all non-nil errors is returned.
 	JoinErrors bool
+
+	// RecoverPanics converts panics in the steps into [RecoveredPanic]
+	// errors. Without it, a panic in any step crashes the process, since
+	// steps run in their own goroutines.
+	//
+	// A recovered panic is handled like any other error: by default it
+	// cancels the remaining steps. As with [Options.RecoverPanics], the
+	// setting applies to all nested steps, and the panic is recovered by the
+	// innermost [Named] step.
+	RecoverPanics bool
 }
 
 // InParallel combines multiple step sequences and runs them concurrently.
@@ -153,9 +189,16 @@ func InParallelWith[T any](
 	providers ...StepsProvider[T],
 ) Step[T] {
 	return func(ctx context.Context, t T) error {
+		if opts.RecoverPanics {
+			ctx = withRecoverPanics(ctx)
+		}
+
 		// expand all providers sequentially to get all steps
 		var allSteps []Step[T]
 		for _, next := range providers {
+			if err := checkBoundary(ctx); err != nil {
+				return err
+			}
 			steps, err := next(ctx, t)
 			if err != nil {
 				return err
@@ -163,8 +206,11 @@ func InParallelWith[T any](
 			allSteps = append(allSteps, steps...)
 		}
 
-		// set up group
-		group, subCtx := errgroup.WithContext(ctx)
+		// set up group; the first failure becomes the cancellation cause
+		// seen by the remaining steps (see context.Cause)
+		subCtx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+		var group errgroup.Group
 		if opts.Limit > 0 {
 			group.SetLimit(opts.Limit)
 		}
@@ -187,14 +233,26 @@ func InParallelWith[T any](
 			}()
 		}
 
-		// run steps
+		// run steps; steps refused because the workflow is draining do not
+		// cancel their siblings, which are left to finish
+		var draining atomic.Bool
 		for _, step := range allSteps {
 			group.Go(func() error {
-				err := step(subCtx, t)
+				if err := checkBoundary(subCtx); err != nil {
+					if errors.Is(err, ErrDraining) {
+						draining.Store(true)
+						return nil
+					}
+					return err
+				}
+				err := runStep(subCtx, step, t)
 				if opts.JoinErrors {
 					errs <- err
 					return nil
 				}
+				if err != nil {
+					cancel(err)
+				}
 				return err
 			})
 		}
@@ -205,8 +263,39 @@ func InParallelWith[T any](
 			close(errs)
 			err = <-joinedErr
 		}
+		if draining.Load() {
+			if err == nil {
+				return ErrDraining
+			}
+			if opts.JoinErrors {
+				return errors.Join(err, ErrDraining)
+			}
+		}
+		return err
+	}
+}
+
+// runStep runs step, converting a panic into a [RecoveredPanic] if panic
+// recovery is enabled in ctx.
+func runStep[T any](ctx context.Context, step Step[T], t T) (err error) {
+	if !recoversPanics(ctx) {
+		return step(ctx, t)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = newRecoveredPanic(ctx, r)
+		}
+	}()
+	return step(ctx, t)
+}
+
+// joinStop combines the errors collected so far with the error that stopped
+// a serial run early, returning the latter unwrapped if there are no others.
+func joinStop(errs []error, err error) error {
+	if len(errs) == 0 {
 		return err
 	}
+	return errors.Join(append(errs, err)...)
 }
 
 // Steps creates a static step sequence from the provided steps.
@@ -5,6 +5,7 @@ package flow
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 )
 
@@ -125,3 +126,121 @@ func TestStepsProviderErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestRecoverPanicsOption(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name      string
+		step      Step[*CountingFlow]
+		expected  int64
+		validator func(error) error
+	}{
+		{
+			name: "Parallel",
+			step: InParallelWith(
+				ParallelOptions{RecoverPanics: true},
+				Steps(PanicWith("boom")),
+			),
+			expected:  0,
+			validator: all(isRecoveredPanic, contains("panic recovered: boom")),
+		},
+		{
+			name: "ParallelJoinErrors",
+			step: InParallelWith(
+				ParallelOptions{RecoverPanics: true, JoinErrors: true},
+				Steps(PanicWith("boom"), IncrementAndFail(error1), Increment(1)),
+			),
+			expected:  2,
+			validator: all(isRecoveredPanic, matches(error1)),
+		},
+		{
+			name: "Serial",
+			step: DoWith(
+				Options{RecoverPanics: true},
+				Increment(1),
+				PanicWith("boom"),
+				Increment(1),
+			),
+			expected:  1,
+			validator: isRecoveredPanic,
+		},
+		{
+			name: "SerialJoinErrors",
+			step: InSerialWith(
+				Options{RecoverPanics: true, JoinErrors: true},
+				Steps(PanicWith("boom"), Increment(1)),
+			),
+			expected:  1,
+			validator: isRecoveredPanic,
+		},
+		{
+			name: "NestedGroupsInherit",
+			step: InParallelWith(
+				ParallelOptions{RecoverPanics: true},
+				Steps(InParallel(Steps(Do(PanicWith("boom"))))),
+			),
+			expected:  0,
+			validator: isRecoveredPanic,
+		},
+		{
+			name: "NoPanic",
+			step: InParallelWith(
+				ParallelOptions{RecoverPanics: true},
+				Steps(Increment(1), Increment(2)),
+			),
+			expected:  3,
+			validator: isNil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			runStepTest(t, tc.step, tc.expected, tc.validator)
+		})
+	}
+
+	t.Run("CancelsSiblings", func(t *testing.T) {
+		t.Parallel()
+		var siblingErr error
+		sibling := func(ctx context.Context, _ *CountingFlow) error {
+			<-ctx.Done()
+			siblingErr = ctx.Err()
+			return siblingErr
+		}
+		err := InParallelWith(
+			ParallelOptions{RecoverPanics: true},
+			Steps(sibling, PanicWith("boom")),
+		)(t.Context(), &CountingFlow{})
+		if err := isRecoveredPanic(err); err != nil {
+			t.Error(err)
+		}
+		if !errors.Is(siblingErr, context.Canceled) {
+			t.Errorf("expected sibling to be canceled, got %v", siblingErr)
+		}
+	})
+
+	t.Run("CapturesStackAndNames", func(t *testing.T) {
+		t.Parallel()
+		err := InParallelWith(
+			ParallelOptions{RecoverPanics: true},
+			Steps(Named("outer", Do(Named("inner", PanicWith("boom"))))),
+		)(t.Context(), &CountingFlow{})
+		var recovered *RecoveredPanic
+		if !errors.As(err, &recovered) {
+			t.Fatalf("expected RecoveredPanic, got %v", err)
+		}
+		if got := strings.Join(recovered.Names, "/"); got != "outer/inner" {
+			t.Errorf("got names %q, want %q", got, "outer/inner")
+		}
+		if !strings.Contains(string(recovered.Stack), "PanicWith") {
+			t.Errorf("expected stack to mention PanicWith, got:\n%s", recovered.Stack)
+		}
+		if got := strings.Join(FailedPath(err), "/"); got != "outer/inner" {
+			t.Errorf("got failed path %q, want %q", got, "outer/inner")
+		}
+		if err.Error() != "outer: inner: panic recovered: boom" {
+			t.Errorf("unexpected error message %q", err.Error())
+		}
+	})
+}
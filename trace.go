@@ -6,41 +6,40 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
+	"slices"
 	"sync"
 	"time"
 )
 
-// NamedError is an error returned by Named and related functions.
-// It wraps the underlying error with the name of the step that failed.
-// Users can use [errors.As] to detect and inspect NamedErrors.
-type NamedError struct {
-	// Name is the name of the step that failed.
-	Name string
-	// Err is the underlying error from the step.
-	Err error
-}
-
-// Error returns the formatted error message.
-func (e NamedError) Error() string {
-	return fmt.Sprintf("%s: %v", e.Name, e.Err)
-}
-
-// Unwrap returns the underlying error.
-func (e NamedError) Unwrap() error {
-	return e.Err
-}
-
 // TraceEvent represents a single execution event in a traced workflow.
 //
 // Each event captures the full path of step names, start time, duration,
 // and any error that occurred during execution.
 type TraceEvent struct {
+	// ID identifies the event within its trace. IDs are assigned from 1 in
+	// the order steps start.
+	ID int `json:"id,omitempty"`
+
+	// ParentID is the ID of the event of the enclosing Named step, or 0 if
+	// the step has none within the trace. Unlike Names, which may be shared
+	// by many events, parent links identify the exact call that started the
+	// step, even when parallel steps interleave.
+	ParentID int `json:"parent_id,omitempty"`
+
 	// Names is the full hierarchical path of step names.
 	// For example: ["parent", "child", "grandchild"]
+	//
+	// Events that are not steps (see Kind) have the path of the step within
+	// which they were recorded, so every event's path extends its parent's.
 	Names []string `json:"step_names"`
 
+	// Kind is what the event records: a step, or for the events [Retry]
+	// records about its attempts, the retry, an attempt, or a backoff.
+	// Events that are not steps have no Status, and are left out of the
+	// totals of the trace (see [Trace.TotalSteps]) and of [Trace.Summarize].
+	Kind EventKind `json:"kind,omitempty"`
+
 	// Start is when the step began execution.
 	Start time.Time `json:"start"`
 
@@ -49,8 +48,99 @@ type TraceEvent struct {
 
 	// Error is the error message if the step failed, empty otherwise.
 	Error string `json:"error,omitempty"`
+
+	// Panicked is true if the step panicked rather than returning an error.
+	// If the panic was recovered (see [RecoverPanics] and the RecoverPanics
+	// run options), Error holds the [RecoveredPanic] message; otherwise it is
+	// "panic".
+	Panicked bool `json:"panicked,omitempty"`
+
+	// Cause explains why the step's context was done if the step failed
+	// after its context was cancelled or timed out, as reported by
+	// [context.Cause]. For example, in [InParallel] it is the error of the
+	// sibling that failed first, and under [WithTimeout] it is the
+	// [DeadlineError] describing the expired limit. Empty otherwise.
+	Cause string `json:"cause,omitempty"`
+
+	// Paused is how long the step was blocked at boundaries by a paused
+	// [Controller]. It is included in Duration; the time spent running is
+	// Duration minus Paused.
+	Paused time.Duration `json:"paused,omitempty"`
+
+	// SkipReason explains why the step was skipped without running, for
+	// example [SkipReasonCheckpointed]. Empty if the step ran.
+	SkipReason string `json:"skip_reason,omitempty"`
+
+	// Status is how the step ended. It is empty while the step is running,
+	// and for events that are not steps (see Kind).
+	Status EventStatus `json:"status,omitempty"`
+
+	// Attempt is the 1-based attempt number of the innermost enclosing
+	// [Retry], or 0 if the step is not within a Retry.
+	Attempt int `json:"attempt,omitempty"`
+
+	// Ensure is the outcome of an [Ensure] step run directly within this
+	// step. Empty otherwise.
+	Ensure EnsureOutcome `json:"ensure,omitempty"`
+
+	// Attrs holds the attributes attached to the step with [Annotate].
+	Attrs map[string]any `json:"attrs,omitempty"`
+
+	// Logs holds the messages recorded against the step with [Event].
+	Logs []TraceLog `json:"logs,omitempty"`
 }
 
+// EventKind is what a trace event records. See [TraceEvent.Kind].
+type EventKind string
+
+const (
+	// KindStep is the kind of the events of Named steps, and of steps
+	// skipped without running.
+	KindStep EventKind = ""
+
+	// KindRetry is the kind of the event spanning a [Retry], from its first
+	// attempt to its last.
+	KindRetry EventKind = "retry"
+
+	// KindAttempt is the kind of the event of an attempt of a [Retry],
+	// holding the attempt's duration and error.
+	KindAttempt EventKind = "attempt"
+
+	// KindBackoff is the kind of the event of a wait between the attempts
+	// of a [Retry], annotated with the delay and the reason for it.
+	KindBackoff EventKind = "backoff"
+)
+
+// EventStatus is how a traced step ended. See [TraceEvent.Status].
+type EventStatus string
+
+const (
+	// StatusSucceeded means the step returned nil.
+	StatusSucceeded EventStatus = "succeeded"
+
+	// StatusFailed means the step returned an error.
+	StatusFailed EventStatus = "failed"
+
+	// StatusCancelled means the step failed because its context was
+	// cancelled, for example by a failing sibling in [InParallel].
+	StatusCancelled EventStatus = "cancelled"
+
+	// StatusTimedOut means the step failed because a deadline expired, for
+	// example under [WithTimeout].
+	StatusTimedOut EventStatus = "timed_out"
+
+	// StatusPanicked means the step panicked, or returned a [RecoveredPanic]
+	// recovered by [RecoverPanics] from an unnamed step within it.
+	StatusPanicked EventStatus = "panicked"
+
+	// StatusSkipped means the step did not run. See [TraceEvent.SkipReason].
+	StatusSkipped EventStatus = "skipped"
+
+	// StatusRetried means the step failed, and the enclosing [Retry] ran it
+	// again.
+	StatusRetried EventStatus = "retried"
+)
+
 // TraceOption configures trace behavior.
 type TraceOption func(*traceOptions)
 
@@ -59,9 +149,21 @@ type traceOptions struct {
 	// StreamTo specifies where to write events as JSON Lines during execution.
 	// Events are written as they complete, enabling real-time monitoring and
 	// ensuring traces are preserved even if the process crashes.
-	// All events are retained in memory for post-execution querying.
-	// If nil, events are only stored in memory.
+	// Events are also retained in memory for post-execution querying,
+	// subject to MaxEvents. If nil, events are only stored in memory.
 	StreamTo io.Writer
+
+	// MaxEvents limits the number of events retained in memory, choosing
+	// which to keep by Retention. Zero means no limit. See [WithMaxEvents].
+	MaxEvents int
+	Retention Retention
+
+	// MaxDepth drops successful events nested more deeply than this. Zero
+	// means no limit. See [WithMaxDepth].
+	MaxDepth int
+
+	// Sampling holds the sampling rules, in order. See [WithSampling].
+	Sampling []samplingRule
 }
 
 // WithStreamTo configures the trace to stream events as JSON Lines to the given writer.
@@ -71,7 +173,12 @@ type traceOptions struct {
 // process crashes. This is different from WriteTo/WriteJSONTo which output a
 // pretty-printed JSON array after execution completes.
 //
-// All events are retained in memory for post-execution querying.
+// Failed steps within an attempt of a [Retry] are written once the Retry
+// decides whether to run the attempt again, so that they carry their final
+// status (see [StatusRetried]).
+//
+// Events are also retained in memory for post-execution querying, unless
+// [WithMaxEvents] limits them.
 //
 // Write failures to the stream are best-effort and do not cause the workflow to fail.
 // This ensures that tracing infrastructure never breaks the workflow itself.
@@ -83,8 +190,8 @@ type traceOptions struct {
 //	trace, err := flow.Traced(workflow, flow.WithStreamTo(f))(ctx, state)
 //
 //	// trace.jsonl contains one JSON object per line:
-//	// {"step_names":["validate"],"start":"...","duration":45000000}
-//	// {"step_names":["connect"],"start":"...","duration":120000000}
+//	// {"id":1,"step_names":["validate"],"start":"...","duration":45000000}
+//	// {"id":2,"step_names":["connect"],"start":"...","duration":120000000}
 //	// ...
 func WithStreamTo(w io.Writer) TraceOption {
 	return func(opts *traceOptions) {
@@ -99,6 +206,56 @@ type trace struct {
 	streamTo io.Writer
 	encoder  *json.Encoder
 	result   *Trace
+	options  traceOptions
+
+	// next is the index of the next event.
+	next eventIdx
+
+	// live holds the events that are still running.
+	live map[eventIdx]*traceEntry
+
+	// retained holds the finished events kept for the result.
+	retained retention
+}
+
+// traceEntry is an event recorded by a trace.
+type traceEntry struct {
+	event TraceEvent
+
+	// parent is the index of the enclosing event, or noEvent.
+	parent eventIdx
+
+	// sampled reports whether sampling and depth limits keep the event even
+	// if it succeeds.
+	sampled bool
+
+	// reserved reports whether the event holds a place among the events
+	// kept by KeepFirst.
+	reserved bool
+
+	// retry is the attempt of a Retry the event was started directly
+	// within, or nil.
+	retry *retryAttempt
+}
+
+// retryAttempt collects the events started directly within an attempt of a
+// Retry, so that they can be marked as retried.
+type retryAttempt struct {
+	// parent is the event of the attempt, or, until the attempt's event
+	// starts, the event of the Retry.
+	parent eventIdx
+
+	// events holds the events started directly within the attempt. Guarded
+	// by the trace's mutex, as are the fields below.
+	events []*traceEntry
+
+	// pending holds the finished events that may yet be marked as retried,
+	// and so are not streamed until the attempt is settled.
+	pending []*traceEntry
+
+	// settled reports whether the Retry has decided whether to run the
+	// attempt again.
+	settled bool
 }
 
 // Trace is the public result type containing execution events and metadata.
@@ -117,17 +274,48 @@ type Trace struct {
 	// TotalSteps is the total number of Named steps executed.
 	// Only Named, NamedExtract, NamedTransform, NamedConsume, and AutoNamed
 	// variants are counted. Unnamed steps are not included.
-	// For filtered traces (from Filter), this equals len(Events).
+	// Events that are not steps (see [TraceEvent.Kind]) are not included.
+	// For filtered traces (from Filter), this is the number of steps in
+	// Events.
 	TotalSteps int
 
 	// TotalErrors is the number of steps that failed with an error.
-	// For filtered traces (from Filter), this is the count of events with errors.
+	// For filtered traces (from Filter), this is the count of steps with errors.
 	TotalErrors int
+
+	// TotalRetries is the number of retries performed by Retry and its
+	// variants, whether or not the retried steps are named.
+	// For filtered traces (from Filter), this is zero.
+	TotalRetries int
+
+	// TotalRetriesRejected is the number of retries refused because the
+	// workflow's RetryBudget was exhausted (see WithRetryBudget).
+	// For filtered traces (from Filter), this is zero.
+	TotalRetriesRejected int
+
+	// StatusCounts is the number of events with each status (see
+	// [TraceEvent.Status]). Events that are still running are not counted.
+	StatusCounts map[EventStatus]int
+
+	// Dropped is the number of events missing from Events because of the
+	// trace's limits (see [WithMaxEvents], [WithMaxDepth], and
+	// [WithSampling]). The totals above still count dropped events.
+	// For filtered traces (from Filter), this is zero.
+	Dropped int
 }
 
-// eventIdx is a type-safe index into the trace's event array.
+// Count returns the number of events with the given status.
+func (t *Trace) Count(status EventStatus) int {
+	return t.StatusCounts[status]
+}
+
+// eventIdx identifies an event while it is recorded. Events are indexed in
+// start order, and an event's ID is its index plus one.
 type eventIdx int
 
+// noEvent is the eventIdx used when there is no enclosing event.
+const noEvent eventIdx = -1
+
 // Traced wraps a workflow and returns an Extract that produces a Trace.
 //
 // The trace records execution events for all Named steps within the workflow.
@@ -137,8 +325,8 @@ type eventIdx int
 // in parallel workflows due to mutex contention. For precise chronological ordering,
 // sort events by their Start time.
 //
-// Options can be provided to configure streaming and context optimization.
-// See [WithStreamTo].
+// Options can be provided to configure streaming and to limit the events kept.
+// See [WithStreamTo], [WithMaxEvents], [WithMaxDepth], and [WithSampling].
 //
 // Example:
 //
@@ -170,14 +358,18 @@ func Traced[T any](step Step[T], opts ...TraceOption) Extract[T, *Trace] {
 	}
 
 	return func(ctx context.Context, t T) (result *Trace, err error) {
+		clock := ClockFrom(ctx)
 		result = &Trace{
-			Start:  time.Now(),
+			Start:  clock.Now(),
 			Events: make([]TraceEvent, 0),
 		}
 
 		tr := &trace{
 			streamTo: options.StreamTo,
 			result:   result,
+			options:  options,
+			live:     make(map[eventIdx]*traceEntry),
+			retained: retention{max: options.MaxEvents, policy: options.Retention},
 		}
 
 		// Initialize JSON encoder if streaming
@@ -188,11 +380,13 @@ func Traced[T any](step Step[T], opts ...TraceOption) Extract[T, *Trace] {
 		f, _ := ctx.Value(flowCtxKey{}).(*flowCtx)
 		f2 := newFlowCtx(ctx, f)
 		f2.trace = tr
+		f2.event = noEvent
 
 		ctx = f2
 		func() {
 			defer func() {
-				result.Duration = time.Since(result.Start)
+				result.Duration = clock.Now().Sub(result.Start)
+				tr.finish()
 
 				// Flush buffered output if streaming
 				if tr.streamTo != nil {
@@ -217,52 +411,340 @@ func getTrace(ctx context.Context) *trace {
 	return f.trace
 }
 
+// currentEvent returns the trace event of the innermost Named step, or
+// noEvent.
+func currentEvent(ctx context.Context) eventIdx {
+	f, ok := ctx.Value(flowCtxKey{}).(*flowCtx)
+	if !ok {
+		return noEvent
+	}
+	return f.event
+}
+
+// run runs body as a traced event of the given kind, within the innermost
+// event. Unlike a Named step, the event does not add to the names of body's
+// context, so it does not change the paths of steps or their errors; it only
+// becomes the innermost event, and so the parent of events within body. Its
+// own names are those of the context.
+func (t *trace) run(ctx context.Context, kind EventKind, body func(context.Context) error) error {
+	idx := t.newEvent(ctx, Names(ctx), kind)
+	var err error
+	finished := false
+	defer func() {
+		t.recordFinish(ctx, idx, err, !finished)
+	}()
+	err = body(withEvent(ctx, idx))
+	finished = true
+	return err
+}
+
 // newEvent creates a new trace event and returns its index.
 //
-// This should be called at the start of step execution. The returned
-// index must be passed to recordFinish when the step completes.
-func (t *trace) newEvent(names []string) eventIdx {
+// This should be called at the start of step execution, with the step's
+// context. The returned index must be passed to recordFinish when the step
+// completes. Only events of kind KindStep count as steps.
+func (t *trace) newEvent(ctx context.Context, names []string, kind EventKind) eventIdx {
+	start := ClockFrom(ctx).Now()
+	parent := noEvent
+	attempt := 0
+	var retry *retryAttempt
+	if f, ok := ctx.Value(flowCtxKey{}).(*flowCtx); ok {
+		parent = f.event
+		attempt = f.attempt
+		if kind == KindStep && f.retryAttempt != nil && f.retryAttempt.parent == parent {
+			retry = f.retryAttempt
+		}
+	}
+	sampled := t.options.sample(names, randFrom(ctx))
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	idx := len(t.result.Events)
-	t.result.Events = append(t.result.Events, TraceEvent{
-		Names: names,
-		Start: time.Now(),
-	})
-	t.result.TotalSteps++
+	idx := t.next
+	t.next++
+	entry := &traceEntry{
+		event: TraceEvent{
+			ID:      int(idx) + 1,
+			Names:   names,
+			Kind:    kind,
+			Start:   start,
+			Attempt: attempt,
+		},
+		parent:  parent,
+		sampled: sampled,
+		retry:   retry,
+	}
+	if parent != noEvent {
+		entry.event.ParentID = int(parent) + 1
+	}
+	t.live[idx] = entry
+	t.retained.start(entry)
+	if kind == KindStep {
+		t.result.TotalSteps++
+	}
+	if retry != nil {
+		retry.events = append(retry.events, entry)
+	}
 
-	return eventIdx(idx)
+	return idx
 }
 
 // recordFinish updates an event with its duration and error (if any).
 //
-// This should be called when a step completes execution.
-func (t *trace) recordFinish(idx eventIdx, err error) {
+// This should be called when a step completes execution, with the same
+// context that was passed to newEvent. If the step panicked, panicked is
+// true and err is either the RecoveredPanic or nil if the panic propagates.
+func (t *trace) recordFinish(ctx context.Context, idx eventIdx, err error, panicked bool) {
+	end := ClockFrom(ctx).Now()
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	event := &t.result.Events[idx]
-	event.Duration = time.Since(event.Start)
+	entry, ok := t.live[idx]
+	if !ok {
+		return
+	}
+	event := &entry.event
+	event.Duration = end.Sub(event.Start)
+	event.Panicked = panicked
+	if panicked && err == nil {
+		err = errPanic
+	}
 	if err != nil {
-		// Unwrap only through NamedErrors to avoid stripping external library wrappers.
+		// Unwrap only through StepErrors to avoid stripping external library wrappers.
 		// This preserves context from external libraries while removing redundant
 		// error messages from our own wrapping chain.
 		recordErr := err
 		for {
-			var namedErr NamedError
-			if errors.As(recordErr, &namedErr) && namedErr.Err != nil {
-				recordErr = namedErr.Err
+			var stepErr *StepError
+			if errors.As(recordErr, &stepErr) && stepErr.Cause != nil {
+				recordErr = stepErr.Cause
 			} else {
 				break
 			}
 		}
 		event.Error = recordErr.Error()
-		t.result.TotalErrors++
+		if event.Kind == KindStep {
+			t.result.TotalErrors++
+		}
+
+		if ctx.Err() != nil {
+			event.Cause = context.Cause(ctx).Error()
+		}
+	}
+	if event.Kind == KindStep {
+		t.setStatus(event, finishStatus(err, panicked))
+	}
+	t.keep(ctx, idx, entry)
+}
+
+// keep decides whether to keep a finished event, streaming it and retaining
+// it in memory if so. The caller must hold the trace's mutex.
+func (t *trace) keep(ctx context.Context, idx eventIdx, entry *traceEntry) {
+	delete(t.live, idx)
+	if !entry.sampled && entry.event.Error == "" {
+		t.retained.release(entry)
+		t.result.Dropped++
+		return
 	}
 
-	// Stream event if enabled (best-effort)
+	// Stream event if enabled (best-effort). Failed events of an attempt
+	// wait until their final status is known.
 	if t.streamTo != nil {
-		_ = t.encoder.Encode(event)
+		if retry := entry.retry; retry != nil && !retry.settled && retryable(entry.event.Status) {
+			retry.pending = append(retry.pending, entry)
+		} else {
+			_ = t.encoder.Encode(&entry.event)
+		}
+	}
+	if !t.retained.add(entry, randFrom(ctx)) {
+		t.result.Dropped++
+	}
+}
+
+// finish stores the retained events in the result, in start order.
+func (t *trace) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := t.retained.events()
+	slices.SortFunc(events, func(a, b *TraceEvent) int {
+		return a.ID - b.ID
+	})
+	t.result.Events = make([]TraceEvent, len(events))
+	for i, event := range events {
+		t.result.Events[i] = *event
+	}
+}
+
+// recordSkip records an event for a step that was skipped without running.
+func (t *trace) recordSkip(ctx context.Context, names []string, reason string) {
+	idx := t.newEvent(ctx, names, KindStep)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry := t.live[idx]
+	entry.event.SkipReason = reason
+	t.setStatus(&entry.event, StatusSkipped)
+	t.keep(ctx, idx, entry)
+}
+
+// recordPause adds a paused duration to an event and all of its enclosing
+// events, which are still running.
+func (t *trace) recordPause(idx eventIdx, paused time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for idx != noEvent {
+		entry, ok := t.live[idx]
+		if !ok {
+			return
+		}
+		entry.event.Paused += paused
+		idx = entry.parent
+	}
+}
+
+// recordEnsure records the outcome of an Ensure step in an event.
+func (t *trace) recordEnsure(idx eventIdx, outcome EnsureOutcome) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if entry, ok := t.live[idx]; ok {
+		entry.event.Ensure = outcome
+	}
+}
+
+// recordAttr sets an attribute of an event.
+func (t *trace) recordAttr(idx eventIdx, key string, value any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.live[idx]
+	if !ok {
+		return
+	}
+	event := &entry.event
+	if event.Attrs == nil {
+		event.Attrs = make(map[string]any)
+	}
+	event.Attrs[key] = value
+}
+
+// recordLog appends a message to an event.
+func (t *trace) recordLog(idx eventIdx, log TraceLog) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if entry, ok := t.live[idx]; ok {
+		entry.event.Logs = append(entry.event.Logs, log)
+	}
+}
+
+// setStatus sets the status of an event, keeping the trace's status counts up
+// to date. The caller must hold the trace's mutex.
+func (t *trace) setStatus(event *TraceEvent, status EventStatus) {
+	if event.Status != "" {
+		t.result.StatusCounts[event.Status]--
+		if t.result.StatusCounts[event.Status] == 0 {
+			delete(t.result.StatusCounts, event.Status)
+		}
+	}
+	event.Status = status
+	if t.result.StatusCounts == nil {
+		t.result.StatusCounts = make(map[EventStatus]int)
+	}
+	t.result.StatusCounts[status]++
+}
+
+// finishStatus returns the status of a step that finished with err. Steps
+// that returned their own error after their context was done still failed.
+func finishStatus(err error, panicked bool) EventStatus {
+	switch {
+	case err == nil:
+		return StatusSucceeded
+	case panicked || isPanic(err):
+		return StatusPanicked
+	case errors.Is(err, context.DeadlineExceeded):
+		return StatusTimedOut
+	case errors.Is(err, context.Canceled):
+		return StatusCancelled
+	default:
+		return StatusFailed
+	}
+}
+
+// isPanic reports whether err is a recovered panic of the step itself, rather
+// than one recorded by a nested Named step.
+func isPanic(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case *RecoveredPanic:
+			return true
+		case *StepError:
+			return false
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// errPanic is recorded for steps whose panic was not recovered.
+var errPanic = errors.New("panic")
+
+// recordRetry counts a retry that was either performed or, if granted is
+// false, rejected by the retry budget. The failed events of a retried attempt
+// are marked as retried. Either way, the attempt is settled.
+func (t *trace) recordRetry(granted bool, attempt *retryAttempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !granted {
+		t.result.TotalRetriesRejected++
+	} else {
+		t.result.TotalRetries++
+		for _, entry := range attempt.events {
+			if retryable(entry.event.Status) {
+				t.setStatus(&entry.event, StatusRetried)
+			}
+		}
+	}
+	t.settle(attempt)
+}
+
+// settleAttempt records that an attempt will not be run again, streaming
+// its held back events with their final status.
+func (t *trace) settleAttempt(attempt *retryAttempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.settle(attempt)
+}
+
+// settle streams the held back events of an attempt. The caller must hold
+// the trace's mutex.
+func (t *trace) settle(attempt *retryAttempt) {
+	if attempt.settled {
+		return
+	}
+	attempt.settled = true
+	for _, entry := range attempt.pending {
+		_ = t.encoder.Encode(&entry.event)
+	}
+	attempt.pending = nil
+}
+
+// retryable reports whether an event with the given status would be marked
+// as retried if its attempt is retried.
+func retryable(status EventStatus) bool {
+	switch status {
+	case "", StatusSucceeded, StatusSkipped:
+		return false
+	default:
+		return true
 	}
 }
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// CriticalPath returns the chain of events that determined the trace's
+// duration, in tree order: the events with no slack (see [Trace.Slack]).
+//
+// In a sequence of steps, every step is on the critical path. Among steps run
+// in parallel, only the one that finished last is, along with its own
+// critical children, so in heavily parallel workflows the critical path shows
+// where speeding up would actually shorten the workflow, which is not
+// necessarily where the slowest step is.
+//
+// The returned pointers refer to the trace's Events.
+func (t *Trace) CriticalPath() []*TraceEvent {
+	slack := t.slack()
+	var path []*TraceEvent
+	_ = t.walk(func(event *TraceEvent, _ int) error {
+		if slack[event] == 0 {
+			path = append(path, event)
+		}
+		return nil
+	})
+	return path
+}
+
+// Slack returns how much each event, by index in Events, could have taken
+// longer without delaying the end of the trace. Events on the critical path
+// (see [Trace.CriticalPath]) have no slack.
+//
+// Dependencies between steps are inferred from their timing: a step is taken
+// to depend on the steps of the same parent that finished before it started,
+// and a parent to finish once its last child does. The time between the end
+// of the last such step and the start of the next is taken to be overhead
+// that does not shrink or grow.
+func (t *Trace) Slack() []time.Duration {
+	slack := t.slack()
+	result := make([]time.Duration, len(t.Events))
+	for i := range t.Events {
+		result[i] = slack[&t.Events[i]]
+	}
+	return result
+}
+
+// slack computes the slack of each event.
+func (t *Trace) slack() map[*TraceEvent]time.Duration {
+	children := make(map[int][]*TraceEvent)
+	for i := range t.Events {
+		if parent := t.Events[i].ParentID; parent != 0 {
+			children[parent] = append(children[parent], &t.Events[i])
+		}
+	}
+	slack := make(map[*TraceEvent]time.Duration, len(t.Events))
+
+	// siblings computes the slack of a group of siblings whose parent's
+	// children may finish as late as limit, and then of their children.
+	var siblings func(events []*TraceEvent, limit time.Time)
+	siblings = func(events []*TraceEvent, limit time.Time) {
+		sortByStart(events)
+		ends := make([]time.Time, len(events))
+		for i, event := range events {
+			ends[i] = eventEnd(event)
+		}
+		sortedEnds := slices.Clone(ends)
+		slices.SortFunc(sortedEnds, func(a, b time.Time) int { return a.Compare(b) })
+
+		// latest[i] is the latest that the successors of events[i:], or the
+		// parent, allow their predecessors to end.
+		latest := make([]time.Time, len(events)+1)
+		latest[len(events)] = limit
+		for i := len(events) - 1; i >= 0; i-- {
+			// The successors of an event are the siblings that started
+			// after it ended.
+			successor := sort.Search(len(events), func(j int) bool {
+				return !events[j].Start.Before(ends[i])
+			})
+			s := max(latest[max(successor, i+1)].Sub(ends[i]), 0)
+			slack[events[i]] = s
+
+			// The event can start as late as the end of the last sibling
+			// that finished before it, plus its slack.
+			before := sort.Search(len(sortedEnds), func(j int) bool {
+				return sortedEnds[j].After(events[i].Start)
+			})
+			last := events[i].Start
+			if before > 0 {
+				last = sortedEnds[before-1]
+			}
+			latest[i] = minTime(last.Add(s), latest[i+1])
+		}
+
+		for _, event := range events {
+			if event.ID == 0 || len(children[event.ID]) == 0 {
+				continue
+			}
+			group := children[event.ID]
+			delete(children, event.ID) // Guard against cycles.
+			siblings(group, latestEnd(group).Add(slack[event]))
+		}
+	}
+
+	roots := t.Roots()
+	siblings(roots, latestEnd(roots))
+	return slack
+}
+
+// eventEnd returns when an event finished.
+func eventEnd(event *TraceEvent) time.Time {
+	return event.Start.Add(event.Duration)
+}
+
+// latestEnd returns the latest end of the given events.
+func latestEnd(events []*TraceEvent) time.Time {
+	var latest time.Time
+	for _, event := range events {
+		if end := eventEnd(event); end.After(latest) {
+			latest = end
+		}
+	}
+	return latest
+}
+
+// minTime returns the earlier of two times.
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
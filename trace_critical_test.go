@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCriticalPath(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := func(id, parent int, name string, from, to int) TraceEvent {
+		return TraceEvent{
+			ID:       id,
+			ParentID: parent,
+			Names:    []string{name},
+			Start:    start.Add(time.Duration(from) * time.Millisecond),
+			Duration: time.Duration(to-from) * time.Millisecond,
+		}
+	}
+
+	// deploy runs a, then b and c in parallel, then d. b's child b1 finishes
+	// early, and a second root, audit, runs in parallel with deploy.
+	trace := &Trace{Events: []TraceEvent{
+		event(1, 0, "deploy", 0, 100),
+		event(2, 1, "a", 0, 10),
+		event(3, 1, "b", 10, 20),
+		event(4, 3, "b1", 10, 15),
+		event(5, 1, "c", 10, 60),
+		event(6, 1, "d", 60, 100),
+		event(7, 0, "audit", 0, 50),
+	}}
+
+	t.Run("Path", func(t *testing.T) {
+		t.Parallel()
+		var names []string
+		for _, e := range trace.CriticalPath() {
+			names = append(names, e.Names[0])
+		}
+		if got := strings.Join(names, " "); got != "deploy a c d" {
+			t.Errorf("got critical path %q, want %q", got, "deploy a c d")
+		}
+	})
+
+	t.Run("Slack", func(t *testing.T) {
+		t.Parallel()
+		want := []time.Duration{0, 0, 40, 40, 0, 0, 50}
+		for i, got := range trace.Slack() {
+			if got != want[i]*time.Millisecond {
+				t.Errorf("%s: got slack %v, want %v", trace.Events[i].Names[0], got, want[i]*time.Millisecond)
+			}
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		if _, err := trace.WriteTextWith(&buf, TextOptions{CriticalPath: true}); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range []string{
+			"deploy (100ms) [CRITICAL]",
+			"  b (10ms) [SLACK: 40ms]",
+			"    b1 (5ms) [SLACK: 40ms]",
+			"audit (50ms) [SLACK: 50ms]",
+		} {
+			if !strings.Contains(buf.String(), line+"\n") {
+				t.Errorf("expected %q in output:\n%s", line, buf.String())
+			}
+		}
+
+		buf.Reset()
+		if _, err := trace.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "CRITICAL") {
+			t.Errorf("expected no markers by default, got:\n%s", buf.String())
+		}
+	})
+
+	t.Run("Traced", func(t *testing.T) {
+		t.Parallel()
+		// fast waits for slow to start, so that they overlap.
+		started := make(chan struct{})
+		trace, err := Traced(Named("deploy", InParallel(Steps(
+			Named("slow", Do(
+				func(context.Context, *CountingFlow) error {
+					close(started)
+					return nil
+				},
+				Sleep[*CountingFlow](20*time.Millisecond),
+			)),
+			Named("fast", func(context.Context, *CountingFlow) error {
+				<-started
+				return nil
+			}),
+		))))(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := trace.CriticalPath()
+		if len(path) != 2 || path[0].Names[0] != "deploy" || path[1].Names[1] != "slow" {
+			t.Errorf("expected deploy and slow on the critical path, got %+v", path)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+		empty := &Trace{}
+		if len(empty.CriticalPath()) != 0 || len(empty.Slack()) != 0 {
+			t.Error("expected no critical path for an empty trace")
+		}
+	})
+}
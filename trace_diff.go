@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DiffOptions configures [DiffTraces].
+type DiffOptions struct {
+	// GroupBy matches the events of the two traces; events with the same
+	// key are compared as a group, by their total duration. If nil, events
+	// are matched by path (see [ByPath]). Use [IgnoringIndices] to match
+	// steps named after the item they process.
+	GroupBy GroupBy
+
+	// MinChange is the smallest change in a group's total duration that is
+	// reported, in either direction.
+	MinChange time.Duration
+
+	// MinRelativeChange is the smallest change in a group's total duration
+	// that is reported, as a fraction of its duration before; for example,
+	// 0.2 reports changes of 20% or more. Groups whose duration before was
+	// zero always pass.
+	//
+	// A change must pass both thresholds to be reported. With both zero,
+	// every change is reported.
+	MinRelativeChange float64
+}
+
+// TraceDiff holds the differences between two traces, from [DiffTraces].
+type TraceDiff struct {
+	// Before and After are the durations of the two traces.
+	Before time.Duration `json:"before"`
+	After  time.Duration `json:"after"`
+
+	// Added holds the groups only in the second trace, and Removed those
+	// only in the first, ordered by key.
+	Added   []StepDiff `json:"added,omitempty"`
+	Removed []StepDiff `json:"removed,omitempty"`
+
+	// Changed holds the groups whose total duration changed by more than
+	// the thresholds, largest change first.
+	Changed []StepDiff `json:"changed,omitempty"`
+
+	// NewErrors holds the groups with errors only in the second trace, and
+	// ResolvedErrors those with errors only in the first, ordered by key.
+	NewErrors      []StepDiff `json:"new_errors,omitempty"`
+	ResolvedErrors []StepDiff `json:"resolved_errors,omitempty"`
+}
+
+// StepDiff describes how a group of events (see [DiffOptions.GroupBy])
+// differs between two traces.
+type StepDiff struct {
+	// Key identifies the group.
+	Key string `json:"key"`
+
+	// Before and After summarize the group in each trace. Before is nil for
+	// added groups, and After for removed ones.
+	Before *StepSummary `json:"before,omitempty"`
+	After  *StepSummary `json:"after,omitempty"`
+
+	// Change is the change in the group's total duration, and
+	// RelativeChange that change as a fraction of the duration before, or
+	// zero if it was zero.
+	Change         time.Duration `json:"change,omitempty"`
+	RelativeChange float64       `json:"relative_change,omitempty"`
+
+	// Error is the first error of the group: in the second trace for new
+	// errors, and in the first for resolved ones.
+	Error string `json:"error,omitempty"`
+}
+
+// DiffTraces compares two traces of a workflow, reporting the steps added
+// and removed, the steps whose duration changed by more than the thresholds
+// in opts, and the steps that started or stopped failing.
+//
+// Example:
+//
+//	diff := flow.DiffTraces(baseline, trace, flow.DiffOptions{
+//	    GroupBy:           flow.IgnoringIndices(flow.ByPath()),
+//	    MinChange:         time.Second,
+//	    MinRelativeChange: 0.2,
+//	})
+//	if len(diff.Changed) > 0 || len(diff.NewErrors) > 0 {
+//	    diff.WriteText(os.Stderr)
+//	    os.Exit(1)
+//	}
+func DiffTraces(before, after *Trace, opts DiffOptions) *TraceDiff {
+	groupBy := opts.GroupBy
+	if groupBy == nil {
+		groupBy = ByPath()
+	}
+	beforeGroups := summaryByKey(before.Summarize(groupBy))
+	afterGroups := summaryByKey(after.Summarize(groupBy))
+	beforeErrors := firstErrors(before, groupBy)
+	afterErrors := firstErrors(after, groupBy)
+
+	diff := &TraceDiff{Before: before.Duration, After: after.Duration}
+	for _, key := range slices.Sorted(mapKeys(beforeGroups, afterGroups)) {
+		b, inBefore := beforeGroups[key]
+		a, inAfter := afterGroups[key]
+		step := StepDiff{Key: key}
+		if inBefore {
+			step.Before = &b
+		}
+		if inAfter {
+			step.After = &a
+		}
+
+		switch {
+		case !inBefore:
+			diff.Added = append(diff.Added, step)
+			if a.Errors > 0 {
+				step.Error = afterErrors[key]
+				diff.NewErrors = append(diff.NewErrors, step)
+			}
+			continue
+		case !inAfter:
+			diff.Removed = append(diff.Removed, step)
+			continue
+		}
+
+		step.Change = a.Total - b.Total
+		if b.Total > 0 {
+			step.RelativeChange = float64(step.Change) / float64(b.Total)
+		}
+		if passesThresholds(step, opts) {
+			diff.Changed = append(diff.Changed, step)
+		}
+		switch {
+		case b.Errors == 0 && a.Errors > 0:
+			step.Error = afterErrors[key]
+			diff.NewErrors = append(diff.NewErrors, step)
+		case b.Errors > 0 && a.Errors == 0:
+			step.Error = beforeErrors[key]
+			diff.ResolvedErrors = append(diff.ResolvedErrors, step)
+		}
+	}
+	slices.SortStableFunc(diff.Changed, func(a, b StepDiff) int {
+		return cmp.Compare(b.Change.Abs(), a.Change.Abs())
+	})
+	return diff
+}
+
+// passesThresholds reports whether a change in duration is large enough to
+// report.
+func passesThresholds(step StepDiff, opts DiffOptions) bool {
+	if step.Change == 0 || step.Change.Abs() < opts.MinChange {
+		return false
+	}
+	if step.Before.Total == 0 {
+		return true
+	}
+	return abs(step.RelativeChange) >= opts.MinRelativeChange
+}
+
+// summaryByKey indexes the groups of a summary by key.
+func summaryByKey(summary *Summary) map[string]StepSummary {
+	groups := make(map[string]StepSummary, len(summary.Groups))
+	for _, group := range summary.Groups {
+		groups[group.Key] = group
+	}
+	return groups
+}
+
+// firstErrors returns the first error of each group of a trace's events.
+func firstErrors(t *Trace, groupBy GroupBy) map[string]string {
+	errs := make(map[string]string)
+	for _, event := range t.Events {
+		if event.Error == "" || event.Kind != KindStep {
+			continue
+		}
+		if key := groupBy(event); key != "" {
+			if _, ok := errs[key]; !ok {
+				errs[key] = event.Error
+			}
+		}
+	}
+	return errs
+}
+
+// mapKeys returns an iterator over the keys of both maps, without duplicates.
+func mapKeys[V any](a, b map[string]V) func(yield func(string) bool) {
+	return func(yield func(string) bool) {
+		for key := range a {
+			if !yield(key) {
+				return
+			}
+		}
+		for key := range b {
+			if _, ok := a[key]; ok {
+				continue
+			}
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// abs returns the absolute value of x.
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Empty reports whether the diff found no differences.
+func (d *TraceDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 &&
+		len(d.NewErrors) == 0 && len(d.ResolvedErrors) == 0
+}
+
+// WriteTo serializes the diff as JSON to the given writer.
+func (d *TraceDiff) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal diff: %w", err)
+	}
+	n, err := w.Write(append(data, '\n'))
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write diff: %w", err)
+	}
+	return int64(n), nil
+}
+
+// WriteText outputs a human-readable diff.
+//
+// Example output:
+//
+//	duration: 4m0s -> 7m0s (+3m0s, +75.0%)
+//	added:
+//	  + deploy.warm-cache (1m0s)
+//	removed:
+//	  - deploy.prefetch (20s)
+//	changed:
+//	  ~ deploy.migrate: 1m0s -> 3m20s (+2m20s, +233.3%)
+//	new errors:
+//	  ! deploy.verify: connection refused
+//	resolved errors:
+//	  * deploy.notify: timeout
+func (d *TraceDiff) WriteText(w io.Writer) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "duration: %s\n", formatChange(d.Before, d.After))
+	section := func(title string, steps []StepDiff, line func(StepDiff) string) {
+		if len(steps) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s:\n", title)
+		for _, step := range steps {
+			fmt.Fprintf(&b, "  %s\n", line(step))
+		}
+	}
+	section("added", d.Added, func(step StepDiff) string {
+		return fmt.Sprintf("+ %s (%s)", step.Key, formatDuration(step.After.Total))
+	})
+	section("removed", d.Removed, func(step StepDiff) string {
+		return fmt.Sprintf("- %s (%s)", step.Key, formatDuration(step.Before.Total))
+	})
+	section("changed", d.Changed, func(step StepDiff) string {
+		return fmt.Sprintf("~ %s: %s", step.Key, formatChange(step.Before.Total, step.After.Total))
+	})
+	section("new errors", d.NewErrors, func(step StepDiff) string {
+		return fmt.Sprintf("! %s: %s", step.Key, step.Error)
+	})
+	section("resolved errors", d.ResolvedErrors, func(step StepDiff) string {
+		return fmt.Sprintf("* %s: %s", step.Key, step.Error)
+	})
+
+	n, err := io.WriteString(w, b.String())
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write diff: %w", err)
+	}
+	return int64(n), nil
+}
+
+// formatChange formats a change from one duration to another.
+func formatChange(before, after time.Duration) string {
+	change := after - before
+	sign := "+"
+	if change < 0 {
+		sign = "-"
+	}
+	s := fmt.Sprintf("%s -> %s (%s%s", formatDuration(before), formatDuration(after), sign, formatDuration(change.Abs()))
+	if before > 0 {
+		s += fmt.Sprintf(", %+.1f%%", 100*float64(change)/float64(before))
+	}
+	return s + ")"
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffTraces(t *testing.T) {
+	t.Parallel()
+
+	event := func(path string, d time.Duration, err string) TraceEvent {
+		return TraceEvent{Names: strings.Split(path, "."), Duration: d, Error: err}
+	}
+	before := &Trace{Duration: 4 * time.Minute, Events: []TraceEvent{
+		event("deploy.migrate", time.Minute, ""),
+		event("deploy.build", 2*time.Minute, ""),
+		event("deploy.prefetch", 20*time.Second, ""),
+		event("deploy.notify", time.Second, "timeout"),
+		event("deploy.item-1", time.Second, ""),
+	}}
+	after := &Trace{Duration: 7 * time.Minute, Events: []TraceEvent{
+		event("deploy.migrate", 3*time.Minute+20*time.Second, ""),
+		event("deploy.build", 2*time.Minute+time.Second, ""),
+		event("deploy.warm-cache", time.Minute, ""),
+		event("deploy.notify", time.Second, ""),
+		event("deploy.verify", 500*time.Millisecond, "connection refused"),
+		event("deploy.item-2", time.Second, ""),
+	}}
+	opts := DiffOptions{MinChange: time.Second, MinRelativeChange: 0.2}
+
+	t.Run("ByPath", func(t *testing.T) {
+		t.Parallel()
+		diff := DiffTraces(before, after, opts)
+		keys := func(steps []StepDiff) string {
+			var keys []string
+			for _, step := range steps {
+				keys = append(keys, step.Key)
+			}
+			return strings.Join(keys, " ")
+		}
+		for _, tc := range []struct {
+			name, got, want string
+		}{
+			{"added", keys(diff.Added), "deploy.item-2 deploy.verify deploy.warm-cache"},
+			{"removed", keys(diff.Removed), "deploy.item-1 deploy.prefetch"},
+			{"changed", keys(diff.Changed), "deploy.migrate"},
+			{"new errors", keys(diff.NewErrors), "deploy.verify"},
+			{"resolved errors", keys(diff.ResolvedErrors), "deploy.notify"},
+		} {
+			if tc.got != tc.want {
+				t.Errorf("%s: got %q, want %q", tc.name, tc.got, tc.want)
+			}
+		}
+		if changed := diff.Changed[0]; changed.Change != 140*time.Second || changed.Before.Total != time.Minute {
+			t.Errorf("unexpected change: %+v", changed)
+		}
+		if diff.NewErrors[0].Error != "connection refused" || diff.ResolvedErrors[0].Error != "timeout" {
+			t.Errorf("unexpected errors: %+v, %+v", diff.NewErrors, diff.ResolvedErrors)
+		}
+	})
+
+	t.Run("IgnoringIndices", func(t *testing.T) {
+		t.Parallel()
+		diff := DiffTraces(before, after, DiffOptions{
+			GroupBy:           IgnoringIndices(ByPath()),
+			MinChange:         opts.MinChange,
+			MinRelativeChange: opts.MinRelativeChange,
+		})
+		for _, step := range append(diff.Added, diff.Removed...) {
+			if strings.Contains(step.Key, "item") {
+				t.Errorf("expected items to match, got %+v", step)
+			}
+		}
+	})
+
+	t.Run("Thresholds", func(t *testing.T) {
+		t.Parallel()
+		if diff := DiffTraces(before, after, DiffOptions{}); len(diff.Changed) != 2 {
+			t.Errorf("expected every change without thresholds, got %+v", diff.Changed)
+		}
+		if diff := DiffTraces(before, after, DiffOptions{MinChange: 5 * time.Minute}); len(diff.Changed) != 0 {
+			t.Errorf("expected no changes above 5m, got %+v", diff.Changed)
+		}
+		if diff := DiffTraces(after, before, opts); len(diff.Changed) != 1 || diff.Changed[0].Change != -140*time.Second {
+			t.Errorf("expected the speedup to be reported, got %+v", diff.Changed)
+		}
+		if diff := DiffTraces(before, before, opts); !diff.Empty() {
+			t.Errorf("expected no differences, got %+v", diff)
+		}
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		t.Parallel()
+		diff := DiffTraces(before, after, opts)
+
+		var text bytes.Buffer
+		n, err := diff.WriteText(&text)
+		if err != nil || n != int64(text.Len()) {
+			t.Fatalf("got %d bytes and %v, wrote %d", n, err, text.Len())
+		}
+		for _, line := range []string{
+			"duration: 4m0s -> 7m0s (+3m0s, +75.0%)",
+			"  + deploy.warm-cache (1m0s)",
+			"  - deploy.prefetch (20s)",
+			"  ~ deploy.migrate: 1m0s -> 3m20s (+2m20s, +233.3%)",
+			"  ! deploy.verify: connection refused",
+			"  * deploy.notify: timeout",
+		} {
+			if !strings.Contains(text.String(), line+"\n") {
+				t.Errorf("expected %q in output:\n%s", line, text.String())
+			}
+		}
+
+		var data bytes.Buffer
+		if _, err := diff.WriteTo(&data); err != nil {
+			t.Fatal(err)
+		}
+		var decoded TraceDiff
+		if err := json.Unmarshal(data.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded.Changed) != 1 || decoded.Changed[0].Change != diff.Changed[0].Change || decoded.Added[0].Before != nil {
+			t.Errorf("unexpected JSON round trip: %s", data.String())
+		}
+	})
+}
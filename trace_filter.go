@@ -3,7 +3,9 @@
 package flow
 
 import (
+	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
@@ -63,7 +65,8 @@ func (t *Trace) Filter(filters ...TraceFilter) *Trace {
 	// Pre-allocate with full capacity (optimistic: many events will match)
 	// This reduces allocations when filtering produces similar-sized results
 	filtered := make([]TraceEvent, 0, len(t.Events))
-	errorCount := 0
+	stepCount, errorCount := 0, 0
+	var statusCounts map[EventStatus]int
 	var totalDuration time.Duration
 	var earliestStart time.Time
 
@@ -78,9 +81,18 @@ func (t *Trace) Filter(filters ...TraceFilter) *Trace {
 		if match {
 			filtered = append(filtered, event)
 			totalDuration += event.Duration
-			if event.Error != "" {
+			if event.Kind == KindStep {
+				stepCount++
+			}
+			if event.Kind == KindStep && event.Error != "" {
 				errorCount++
 			}
+			if event.Status != "" {
+				if statusCounts == nil {
+					statusCounts = make(map[EventStatus]int)
+				}
+				statusCounts[event.Status]++
+			}
 			// Track earliest start time
 			if earliestStart.IsZero() || event.Start.Before(earliestStart) {
 				earliestStart = event.Start
@@ -95,11 +107,12 @@ func (t *Trace) Filter(filters ...TraceFilter) *Trace {
 	}
 
 	return &Trace{
-		Events:      filtered,
-		Start:       startTime,
-		Duration:    totalDuration,
-		TotalSteps:  len(filtered),
-		TotalErrors: errorCount,
+		Events:       filtered,
+		Start:        startTime,
+		Duration:     totalDuration,
+		TotalSteps:   stepCount,
+		TotalErrors:  errorCount,
+		StatusCounts: statusCounts,
 	}
 }
 
@@ -131,6 +144,81 @@ func NoError() TraceFilter {
 	}
 }
 
+// Panicked returns a filter that matches events for steps that panicked.
+func Panicked() TraceFilter {
+	return func(event TraceEvent) bool {
+		return event.Panicked
+	}
+}
+
+// HasStatus returns a filter that matches events with one of the given
+// statuses (see [TraceEvent.Status]).
+//
+// Example:
+//
+//	interrupted := trace.Filter(flow.HasStatus(flow.StatusCancelled, flow.StatusTimedOut))
+func HasStatus(statuses ...EventStatus) TraceFilter {
+	return func(event TraceEvent) bool {
+		return slices.Contains(statuses, event.Status)
+	}
+}
+
+// HasKind returns a filter that matches events of one of the given kinds (see
+// [TraceEvent.Kind]).
+//
+// Example:
+//
+//	backoffs := trace.Filter(flow.HasKind(flow.KindBackoff))
+//	steps := trace.Filter(flow.HasKind(flow.KindStep))
+func HasKind(kinds ...EventKind) TraceFilter {
+	return func(event TraceEvent) bool {
+		return slices.Contains(kinds, event.Kind)
+	}
+}
+
+// Skipped returns a filter that matches events for steps that were skipped
+// without running (see [TraceEvent.SkipReason]). If reasons are given, only
+// steps skipped for one of them match.
+//
+// Example:
+//
+//	plan := trace.Filter(flow.Skipped(flow.SkipReasonDryRun))
+func Skipped(reasons ...string) TraceFilter {
+	return func(event TraceEvent) bool {
+		if event.SkipReason == "" {
+			return false
+		}
+		return len(reasons) == 0 || slices.Contains(reasons, event.SkipReason)
+	}
+}
+
+// HasAttr returns a filter that matches events with the given attribute (see
+// [Annotate]).
+func HasAttr(key string) TraceFilter {
+	return func(event TraceEvent) bool {
+		_, ok := event.Attrs[key]
+		return ok
+	}
+}
+
+// AttrEquals returns a filter that matches events whose attribute key (see
+// [Annotate]) equals value.
+//
+// Values are compared by their formatted text, so that, for example, an
+// int64 attribute matches an int value, and also the float64 it becomes when
+// a trace is decoded from JSON.
+//
+// Example:
+//
+//	usEast := trace.Filter(flow.AttrEquals("region", "us-east-2"))
+func AttrEquals(key string, value any) TraceFilter {
+	want := fmt.Sprint(value)
+	return func(event TraceEvent) bool {
+		got, ok := event.Attrs[key]
+		return ok && fmt.Sprint(got) == want
+	}
+}
+
 // NameMatches returns a filter that matches events where the step name
 // (last element of Names) matches the glob pattern.
 //
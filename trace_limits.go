@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Retention selects which events a trace keeps in memory once it holds as
+// many as [WithMaxEvents] allows.
+type Retention int
+
+const (
+	// KeepFirst keeps the first events to start, so the outermost steps of a
+	// long workflow are kept and the later events are dropped.
+	KeepFirst Retention = iota
+
+	// KeepLast keeps the last events to finish, like a ring buffer, so the
+	// trace shows what the workflow did most recently.
+	KeepLast
+
+	// KeepSample keeps a uniform random sample of the events, chosen by
+	// reservoir sampling with the workflow's random number generator (see
+	// [WithRand]).
+	KeepSample
+)
+
+// WithMaxEvents limits the number of events a trace keeps in memory to n,
+// choosing which to keep by retention. Zero or less means no limit.
+//
+// Dropped events are still counted in the trace's totals, such as
+// [Trace.TotalSteps], [Trace.TotalErrors], and [Trace.StatusCounts], and in
+// [Trace.Dropped]. With [WithStreamTo], every event that sampling keeps is
+// streamed, even if it is not retained in memory.
+//
+// Example:
+//
+//	// Keep the most recent 10,000 events of a long-running workflow.
+//	trace, err := flow.Traced(workflow, flow.WithMaxEvents(10_000, flow.KeepLast))(ctx, state)
+func WithMaxEvents(n int, retention Retention) TraceOption {
+	return func(opts *traceOptions) {
+		opts.MaxEvents = max(n, 0)
+		opts.Retention = retention
+	}
+}
+
+// WithMaxDepth drops the events of successful steps nested more than depth
+// steps deep, as measured by the length of [TraceEvent.Names]. Events of
+// steps that failed are kept. Zero or less means no limit.
+//
+// Dropped events are still counted in the trace's totals, and in
+// [Trace.Dropped], but are neither retained nor streamed.
+func WithMaxDepth(depth int) TraceOption {
+	return func(opts *traceOptions) {
+		opts.MaxDepth = max(depth, 0)
+	}
+}
+
+// WithSampling keeps only the given fraction, between 0 and 1, of the events
+// of successful steps whose dotted path matches pattern (see [PathMatches]).
+// The pattern must match the whole path: "process.item" matches only the
+// process.item step itself, while "process.item.*" matches all the steps
+// within it.
+// Events of steps that failed are always kept. Whether an event is sampled is
+// decided independently for each event, with the workflow's random number
+// generator (see [WithRand]).
+//
+// WithSampling may be given more than once; each event is sampled by the
+// first rule whose pattern matches its path. Events matching no rule are
+// kept.
+//
+// Dropped events are still counted in the trace's totals, and in
+// [Trace.Dropped], but are neither retained nor streamed.
+//
+// Example:
+//
+//	// Keep 1% of the events of the steps of each item, and every failed one.
+//	trace, err := flow.Traced(workflow, flow.WithSampling("process.item.*", 0.01))(ctx, state)
+func WithSampling(pattern string, rate float64) TraceOption {
+	return func(opts *traceOptions) {
+		opts.Sampling = append(opts.Sampling, samplingRule{
+			pattern: pattern,
+			rate:    min(max(rate, 0), 1),
+		})
+	}
+}
+
+// samplingRule keeps the given fraction of the events matching a pattern.
+type samplingRule struct {
+	pattern string
+	rate    float64
+}
+
+// sample reports whether an event with the given names is kept even if its
+// step succeeds.
+func (o *traceOptions) sample(names []string, rng random) bool {
+	if o.MaxDepth > 0 && len(names) > o.MaxDepth {
+		return false
+	}
+	if len(o.Sampling) == 0 {
+		return true
+	}
+	path := strings.Join(names, ".")
+	for _, rule := range o.Sampling {
+		if matched, err := filepath.Match(rule.pattern, path); err == nil && matched {
+			return rng.Float64() < rule.rate
+		}
+	}
+	return true
+}
+
+// retention holds the finished events a trace keeps in memory.
+type retention struct {
+	// max is the number of events to keep, or zero for no limit.
+	max    int
+	policy Retention
+
+	// retained holds the kept events. For KeepLast, it is a ring buffer
+	// whose oldest event is at next.
+	retained []*TraceEvent
+	next     int
+
+	// reserved is the number of running events holding a place for
+	// KeepFirst.
+	reserved int
+
+	// seen is the number of events offered for KeepSample.
+	seen int64
+}
+
+// start notes that an event has started. For KeepFirst, the event holds a
+// place if one is left.
+func (r *retention) start(entry *traceEntry) {
+	if r.max == 0 || r.policy != KeepFirst {
+		return
+	}
+	if len(r.retained)+r.reserved < r.max {
+		r.reserved++
+		entry.reserved = true
+	}
+}
+
+// release gives up the place of an event that will not be kept.
+func (r *retention) release(entry *traceEntry) {
+	if entry.reserved {
+		entry.reserved = false
+		r.reserved--
+	}
+}
+
+// add offers a finished event, reporting false if an event was dropped as a
+// result: either this one, or one it replaced.
+func (r *retention) add(entry *traceEntry, rng random) bool {
+	event := &entry.event
+	switch {
+	case r.max == 0:
+		r.retained = append(r.retained, event)
+		return true
+
+	case r.policy == KeepFirst:
+		if !entry.reserved {
+			return false
+		}
+		r.release(entry)
+		r.retained = append(r.retained, event)
+		return true
+
+	case r.policy == KeepLast:
+		if len(r.retained) < r.max {
+			r.retained = append(r.retained, event)
+			return true
+		}
+		r.retained[r.next] = event
+		r.next = (r.next + 1) % r.max
+		return false
+
+	default:
+		// Reservoir sampling (Algorithm R): the nth event replaces a random
+		// kept event with probability max/n.
+		r.seen++
+		if len(r.retained) < r.max {
+			r.retained = append(r.retained, event)
+			return true
+		}
+		if i := rng.Int64N(r.seen); i < int64(r.max) {
+			r.retained[i] = event
+		}
+		return false
+	}
+}
+
+// events returns the kept events, in no particular order.
+func (r *retention) events() []*TraceEvent {
+	return r.retained
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestTraceLimits(t *testing.T) {
+	t.Parallel()
+
+	// process runs 100 items, every tenth of which fails.
+	items := make([]Step[*CountingFlow], 100)
+	for i := range items {
+		if i%10 == 0 {
+			items[i] = Named("item", IncrementAndFail(error1))
+		} else {
+			items[i] = Named("item", Increment(1))
+		}
+	}
+	process := Named("process", DoWith(Options{JoinErrors: true}, items...))
+
+	run := func(t *testing.T, opts ...TraceOption) *Trace {
+		t.Helper()
+		rng := rand.New(rand.NewPCG(1, 2))
+		trace, _ := Traced(WithRand(rng, process), opts...)(t.Context(), &CountingFlow{})
+		// Totals count every event, whether or not it was kept.
+		if trace.TotalSteps != 101 || trace.TotalErrors != 11 {
+			t.Errorf("got %d steps and %d errors, want 101 and 11", trace.TotalSteps, trace.TotalErrors)
+		}
+		if trace.Count(StatusSucceeded) != 90 || trace.Count(StatusFailed) != 11 {
+			t.Errorf("unexpected status counts: %v", trace.StatusCounts)
+		}
+		if len(trace.Events)+trace.Dropped != 101 {
+			t.Errorf("got %d events and %d dropped, want 101 in all", len(trace.Events), trace.Dropped)
+		}
+		for i := 1; i < len(trace.Events); i++ {
+			if trace.Events[i].ID <= trace.Events[i-1].ID {
+				t.Fatalf("expected events in start order, got IDs %d then %d", trace.Events[i-1].ID, trace.Events[i].ID)
+			}
+		}
+		return trace
+	}
+
+	t.Run("Unlimited", func(t *testing.T) {
+		t.Parallel()
+		if trace := run(t); trace.Dropped != 0 {
+			t.Errorf("expected no dropped events, got %d", trace.Dropped)
+		}
+	})
+
+	t.Run("KeepFirst", func(t *testing.T) {
+		t.Parallel()
+		trace := run(t, WithMaxEvents(10, KeepFirst))
+		if len(trace.Events) != 10 || trace.Events[0].Names[0] != "process" || trace.Events[9].ID != 10 {
+			t.Errorf("expected the first 10 events, got %+v", trace.Events)
+		}
+	})
+
+	t.Run("KeepLast", func(t *testing.T) {
+		t.Parallel()
+		trace := run(t, WithMaxEvents(10, KeepLast))
+		if len(trace.Events) != 10 || trace.Events[0].ID != 1 || trace.Events[1].ID != 93 {
+			t.Errorf("expected the process and the last 9 items, got %+v", trace.Events)
+		}
+		// The parent of the kept items is kept, so they render as a tree.
+		if children := trace.Children(1); len(children) != 9 {
+			t.Errorf("expected 9 children of the process, got %d", len(children))
+		}
+	})
+
+	t.Run("KeepSample", func(t *testing.T) {
+		t.Parallel()
+		first := run(t, WithMaxEvents(10, KeepSample))
+		second := run(t, WithMaxEvents(10, KeepSample))
+		if len(first.Events) != 10 {
+			t.Fatalf("expected 10 events, got %d", len(first.Events))
+		}
+		for i := range first.Events {
+			if first.Events[i].ID != second.Events[i].ID {
+				t.Fatalf("expected the same sample for the same seed")
+			}
+		}
+	})
+
+	t.Run("Sampling", func(t *testing.T) {
+		t.Parallel()
+		var stream bytes.Buffer
+		trace := run(t, WithSampling("process.item", 0), WithStreamTo(&stream))
+		if len(trace.Events) != 11 || trace.Count(StatusFailed) != 11 {
+			t.Errorf("expected the process and the failed items, got %+v", trace.Events)
+		}
+		if lines := bytes.Count(stream.Bytes(), []byte("\n")); lines != 11 {
+			t.Errorf("expected 11 streamed events, got %d", lines)
+		}
+
+		// Half of the items are kept, and all failed ones.
+		trace = run(t, WithSampling("process.*", 0.5))
+		if n := len(trace.Events); n < 30 || n > 70 {
+			t.Errorf("expected about half the items, got %d events", n)
+		}
+		if len(trace.Filter(HasError()).Events) != 11 {
+			t.Error("expected every failed event to be kept")
+		}
+
+		// The first matching rule applies.
+		trace = run(t, WithSampling("process.item", 1), WithSampling("process.*", 0))
+		if trace.Dropped != 0 {
+			t.Errorf("expected no dropped events, got %d", trace.Dropped)
+		}
+	})
+
+	t.Run("MaxDepth", func(t *testing.T) {
+		t.Parallel()
+		trace := run(t, WithMaxDepth(1))
+		if len(trace.Events) != 11 || trace.Dropped != 90 {
+			t.Errorf("expected the process and the failed items, got %+v", trace.Events)
+		}
+	})
+}
@@ -7,7 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 )
 
 // WriteTo serializes the trace as JSON to the given writer.
@@ -43,8 +46,10 @@ func (t *Trace) WriteTo(w io.Writer) (int64, error) {
 // WriteText outputs a human-readable tree view of the trace.
 //
 // The tree view shows the hierarchical structure of steps with their
-// durations. Indentation reflects nesting depth, and the displayed
-// name is the last element of the step path.
+// durations. Each step is listed under the step that started it (see
+// [TraceEvent.ParentID]), so the tree reflects the real call structure even
+// when parallel steps interleave. Siblings are listed in start order, and the
+// displayed name is the last element of the step path.
 //
 // Example output:
 //
@@ -54,52 +59,132 @@ func (t *Trace) WriteTo(w io.Writer) (int64, error) {
 //	  create-tables (1.8s)
 //	  create-indexes (500ms)
 //
-// For parallel workflows where events may be interleaved, the tree view
-// shows execution order, not logical nesting, which can be misleading.
-// For parallel workflows, use [WriteFlatText] instead, which shows full
-// paths and makes event relationships clearer.
+// In a filtered trace, steps whose parent was filtered out are listed at the
+// top level.
+//
+// WriteText is the same as [Trace.WriteTextWith] with the default
+// [TextOptions].
 func (t *Trace) WriteText(w io.Writer) (int64, error) {
+	return t.WriteTextWith(w, TextOptions{})
+}
+
+// TextOptions configures [Trace.WriteTextWith].
+type TextOptions struct {
+	// CriticalPath marks the events on the critical path with [CRITICAL],
+	// and shows the slack of the other events (see [Trace.CriticalPath] and
+	// [Trace.Slack]).
+	CriticalPath bool
+}
+
+// WriteTextWith is like [Trace.WriteText] with custom options.
+//
+// Example output with CriticalPath:
+//
+//	deploy (3.1s) [CRITICAL]
+//	  build (3s) [CRITICAL]
+//	  lint (400ms) [SLACK: 2.6s]
+//	  publish (100ms) [CRITICAL]
+func (t *Trace) WriteTextWith(w io.Writer, opts TextOptions) (int64, error) {
+	var slack map[*TraceEvent]time.Duration
+	if opts.CriticalPath {
+		slack = t.slack()
+	}
 	var totalBytes int64
-	for _, event := range t.Events {
-		// Indentation based on depth
-		depth := len(event.Names)
-		if depth > 0 {
-			depth-- // Root level has no indent
-		}
+	err := t.walk(func(event *TraceEvent, depth int) error {
 		indent := strings.Repeat("  ", depth)
 
-		// Get the step name (last element of path)
+		// Get the step name (last element of path), or the kind of events
+		// that are not steps
 		name := "<unknown>"
-		if len(event.Names) > 0 {
+		if event.Kind != KindStep {
+			name = string(event.Kind)
+		} else if len(event.Names) > 0 {
 			name = event.Names[len(event.Names)-1]
 		}
 
 		// Format duration
 		duration := event.Duration.String()
+		if event.Paused > 0 {
+			duration += ", paused " + event.Paused.String()
+		}
 
 		// Format line
-		line := fmt.Sprintf("%s%s (%s)", indent, name, duration)
-		if event.Error != "" {
-			line += fmt.Sprintf(" [ERROR: %s]", event.Error)
+		annotations := eventAnnotations(*event)
+		if slack != nil {
+			if s := slack[event]; s == 0 {
+				annotations += " [CRITICAL]"
+			} else {
+				annotations += fmt.Sprintf(" [SLACK: %s]", s)
+			}
 		}
-		line += "\n"
+		line := fmt.Sprintf("%s%s (%s)%s\n", indent, name, duration, annotations)
 
 		n, err := w.Write([]byte(line))
 		totalBytes += int64(n)
 		if err != nil {
-			return totalBytes, fmt.Errorf("failed to write text: %w", err)
+			return fmt.Errorf("failed to write text: %w", err)
 		}
+		return nil
+	})
+	return totalBytes, err
+}
+
+// eventAnnotations formats the bracketed annotations that follow an event's
+// duration in text output, such as its error.
+func eventAnnotations(event TraceEvent) string {
+	var b strings.Builder
+	if event.Panicked || event.Status == StatusPanicked {
+		fmt.Fprintf(&b, " [PANIC: %s]", event.Error)
+	} else if event.Error != "" {
+		fmt.Fprintf(&b, " [%s: %s]", errorLabel(event.Status), event.Error)
 	}
+	if event.Attempt > 0 {
+		fmt.Fprintf(&b, " [ATTEMPT %d]", event.Attempt)
+	}
+	if event.Cause != "" && event.Cause != event.Error {
+		fmt.Fprintf(&b, " [CAUSE: %s]", event.Cause)
+	}
+	if event.SkipReason != "" {
+		fmt.Fprintf(&b, " [SKIPPED: %s]", event.SkipReason)
+	}
+	if event.Ensure != "" {
+		fmt.Fprintf(&b, " [ENSURE: %s]", event.Ensure)
+	}
+	if len(event.Attrs) > 0 {
+		keys := slices.Sorted(maps.Keys(event.Attrs))
+		b.WriteString(" {")
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "%s=%v", key, event.Attrs[key])
+		}
+		b.WriteString("}")
+	}
+	return b.String()
+}
 
-	return totalBytes, nil
+// errorLabel returns the label for the error of an event with the given
+// status.
+func errorLabel(status EventStatus) string {
+	switch status {
+	case StatusCancelled:
+		return "CANCELLED"
+	case StatusTimedOut:
+		return "TIMED OUT"
+	case StatusRetried:
+		return "RETRIED"
+	default:
+		return "ERROR"
+	}
 }
 
 // WriteFlatText outputs a human-readable flat list of events.
 //
 // Unlike [WriteText], this method shows events in a chronological list with
 // full paths (e.g., "parent > child") and no tree indentation. This format
-// is recommended for analyzing parallel workflows where tree structure may
-// be misleading due to event interleaving.
+// is useful for seeing the order in which steps of parallel workflows
+// started.
 //
 // Example output:
 //
@@ -119,21 +204,24 @@ func (t *Trace) WriteText(w io.Writer) (int64, error) {
 func (t *Trace) WriteFlatText(w io.Writer) (int64, error) {
 	var totalBytes int64
 	for _, event := range t.Events {
-		// Build full path
+		// Build full path, ending with the kind of events that are not steps
+		names := event.Names
+		if event.Kind != KindStep {
+			names = append(slices.Clip(names), string(event.Kind))
+		}
 		path := "<unknown>"
-		if len(event.Names) > 0 {
-			path = strings.Join(event.Names, " > ")
+		if len(names) > 0 {
+			path = strings.Join(names, " > ")
 		}
 
 		// Format duration
 		duration := event.Duration.String()
+		if event.Paused > 0 {
+			duration += ", paused " + event.Paused.String()
+		}
 
 		// Format line
-		line := fmt.Sprintf("%s (%s)", path, duration)
-		if event.Error != "" {
-			line += fmt.Sprintf(" [ERROR: %s]", event.Error)
-		}
-		line += "\n"
+		line := fmt.Sprintf("%s (%s)%s\n", path, duration, eventAnnotations(event))
 
 		n, err := w.Write([]byte(line))
 		totalBytes += int64(n)
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// ReadTrace decodes a trace written by [Trace.WriteTo], a JSON array of
+// events, or streamed by [WithStreamTo], one event per line. The format is
+// detected from the first character.
+//
+// Since streamed events are written as they complete, a process that crashed
+// may leave a partial last line; it is ignored. Any other malformed line is an
+// error.
+//
+// Events are ordered by ID, as in the trace returned by [Traced]. Start,
+// Duration, TotalSteps, TotalErrors, and StatusCounts are rebuilt from the
+// events, while TotalRetries, TotalRetriesRejected, and Dropped, which are
+// not recorded in events, are zero. Attribute values are decoded as JSON
+// values, so numbers become float64.
+//
+// Example:
+//
+//	f, err := os.Open("trace.jsonl")
+//	if err != nil {
+//	    return err
+//	}
+//	defer f.Close()
+//	trace, err := flow.ReadTrace(f)
+//	if err != nil {
+//	    return err
+//	}
+//	trace.Filter(flow.HasError()).WriteText(os.Stdout)
+func ReadTrace(r io.Reader) (*Trace, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trace: %w", err)
+	}
+
+	var events []TraceEvent
+	switch first {
+	case 0:
+		// An empty trace.
+	case '[':
+		if err := json.NewDecoder(br).Decode(&events); err != nil {
+			return nil, fmt.Errorf("failed to decode trace: %w", err)
+		}
+	case '{':
+		if events, err = readTraceLines(br); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to decode trace: unexpected %q, want a JSON array or JSON Lines", first)
+	}
+
+	slices.SortStableFunc(events, func(a, b TraceEvent) int {
+		return a.ID - b.ID
+	})
+	trace := &Trace{Events: events}
+	trace.rebuildTotals()
+	return trace, nil
+}
+
+// peekNonSpace skips leading white space and returns the next byte without
+// consuming it, or zero at the end of the input.
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, r.UnreadByte()
+	}
+}
+
+// readTraceLines decodes events in JSON Lines format, ignoring a malformed
+// last line.
+func readTraceLines(r io.Reader) ([]TraceEvent, error) {
+	var events []TraceEvent
+	var partial error
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		// A malformed line is only an error if another line follows it.
+		if partial != nil {
+			return nil, partial
+		}
+		var event TraceEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			partial = fmt.Errorf("failed to decode trace line %d: %w", line, err)
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trace: %w", err)
+	}
+	return events, nil
+}
+
+// MergeTraces combines several traces, such as the streams of the processes
+// of a distributed workflow or of successive runs of a retried job, into one
+// trace with events ordered by start time.
+//
+// Event IDs are renumbered so that they do not collide, keeping each event
+// under its parent. The totals of the traces are added up, and the merged
+// trace spans from the earliest start to the latest end.
+//
+// Example:
+//
+//	worker1, err := flow.ReadTrace(f1)
+//	if err != nil {
+//	    return err
+//	}
+//	worker2, err := flow.ReadTrace(f2)
+//	if err != nil {
+//	    return err
+//	}
+//	flow.MergeTraces(worker1, worker2).WriteText(os.Stdout)
+func MergeTraces(traces ...*Trace) *Trace {
+	merged := &Trace{}
+	offset := 0
+	var end time.Time
+	for _, t := range traces {
+		maxID := 0
+		for _, event := range t.Events {
+			if event.ID != 0 {
+				event.ID += offset
+			}
+			if event.ParentID != 0 {
+				event.ParentID += offset
+			}
+			maxID = max(maxID, event.ID)
+			merged.Events = append(merged.Events, event)
+		}
+		offset = max(offset, maxID)
+
+		if !t.Start.IsZero() {
+			if merged.Start.IsZero() || t.Start.Before(merged.Start) {
+				merged.Start = t.Start
+			}
+			if tEnd := t.Start.Add(t.Duration); tEnd.After(end) {
+				end = tEnd
+			}
+		}
+		merged.TotalSteps += t.TotalSteps
+		merged.TotalErrors += t.TotalErrors
+		merged.TotalRetries += t.TotalRetries
+		merged.TotalRetriesRejected += t.TotalRetriesRejected
+		merged.Dropped += t.Dropped
+		for status, count := range t.StatusCounts {
+			if merged.StatusCounts == nil {
+				merged.StatusCounts = make(map[EventStatus]int)
+			}
+			merged.StatusCounts[status] += count
+		}
+	}
+	if !merged.Start.IsZero() {
+		merged.Duration = end.Sub(merged.Start)
+	}
+
+	slices.SortStableFunc(merged.Events, func(a, b TraceEvent) int {
+		return a.Start.Compare(b.Start)
+	})
+	return merged
+}
+
+// rebuildTotals computes the trace's start, duration, and totals from its
+// events.
+func (t *Trace) rebuildTotals() {
+	var end time.Time
+	for _, event := range t.Events {
+		if t.Start.IsZero() || event.Start.Before(t.Start) {
+			t.Start = event.Start
+		}
+		if eventEnd := event.Start.Add(event.Duration); eventEnd.After(end) {
+			end = eventEnd
+		}
+		if event.Kind != KindStep {
+			continue
+		}
+		t.TotalSteps++
+		if event.Error != "" {
+			t.TotalErrors++
+		}
+		if event.Status != "" {
+			if t.StatusCounts == nil {
+				t.StatusCounts = make(map[EventStatus]int)
+			}
+			t.StatusCounts[event.Status]++
+		}
+	}
+	if !t.Start.IsZero() {
+		t.Duration = end.Sub(t.Start)
+	}
+}
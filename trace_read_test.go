@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTrace(t *testing.T) {
+	t.Parallel()
+
+	// The workflow's trace is both streamed and written as an array.
+	var stream bytes.Buffer
+	original, err := Traced(Named("deploy", Do(
+		Named("migrate", Increment(1)),
+		Named("verify", IncrementAndFail(error1)),
+	)), WithStreamTo(&stream))(t.Context(), &CountingFlow{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	var array bytes.Buffer
+	if _, err := original.WriteTo(&array); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, trace *Trace) {
+		t.Helper()
+		if len(trace.Events) != 3 || trace.TotalSteps != 3 || trace.TotalErrors != 2 {
+			t.Fatalf("unexpected trace: %+v", trace)
+		}
+		for i, event := range trace.Events {
+			if event.ID != original.Events[i].ID || event.Error != original.Events[i].Error {
+				t.Errorf("event %d: got %+v, want %+v", i, event, original.Events[i])
+			}
+		}
+		if trace.Count(StatusFailed) != 2 || trace.Count(StatusSucceeded) != 1 {
+			t.Errorf("unexpected status counts: %v", trace.StatusCounts)
+		}
+		if !trace.Start.Equal(original.Events[0].Start) || trace.Duration != original.Events[0].Duration {
+			t.Errorf("got start %v and duration %v, want those of the root event", trace.Start, trace.Duration)
+		}
+	}
+
+	t.Run("Array", func(t *testing.T) {
+		t.Parallel()
+		trace, err := ReadTrace(bytes.NewReader(array.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, trace)
+	})
+
+	t.Run("Lines", func(t *testing.T) {
+		t.Parallel()
+		trace, err := ReadTrace(bytes.NewReader(stream.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, trace)
+	})
+
+	t.Run("PartialLastLine", func(t *testing.T) {
+		t.Parallel()
+		data := stream.String() + `{"id":4,"step_names":["dep`
+		trace, err := ReadTrace(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, trace)
+	})
+
+	t.Run("MalformedLine", func(t *testing.T) {
+		t.Parallel()
+		data := "{\"id\":1}\n{oops\n{\"id\":2}\n"
+		if _, err := ReadTrace(strings.NewReader(data)); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("expected an error on line 2, got %v", err)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+		trace, err := ReadTrace(strings.NewReader(" \n"))
+		if err != nil || len(trace.Events) != 0 || trace.TotalSteps != 0 {
+			t.Errorf("expected an empty trace, got %+v, %v", trace, err)
+		}
+	})
+
+	t.Run("NotJSON", func(t *testing.T) {
+		t.Parallel()
+		if _, err := ReadTrace(strings.NewReader("deploy (1s)\n")); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestMergeTraces(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := func(id, parent int, name string, from, to int, err string) TraceEvent {
+		return TraceEvent{
+			ID:       id,
+			ParentID: parent,
+			Names:    []string{name},
+			Start:    start.Add(time.Duration(from) * time.Millisecond),
+			Duration: time.Duration(to-from) * time.Millisecond,
+			Error:    err,
+			Status:   StatusSucceeded,
+		}
+	}
+	worker1 := &Trace{Events: []TraceEvent{
+		event(1, 0, "worker1", 10, 50, ""),
+		event(2, 1, "task-a", 10, 50, ""),
+	}}
+	worker2 := &Trace{Events: []TraceEvent{
+		event(1, 0, "worker2", 0, 100, ""),
+		event(2, 1, "task-b", 0, 100, "boom"),
+	}, TotalRetries: 2}
+	worker1.rebuildTotals()
+	worker2.rebuildTotals()
+
+	merged := MergeTraces(worker1, worker2)
+	var names []string
+	for _, e := range merged.Events {
+		names = append(names, e.Names[0])
+	}
+	if got := strings.Join(names, " "); got != "worker2 task-b worker1 task-a" {
+		t.Errorf("got events %q, want them in start order", got)
+	}
+	if roots := merged.Roots(); len(roots) != 2 {
+		t.Fatalf("expected 2 roots, got %d", len(roots))
+	}
+	for _, root := range merged.Roots() {
+		children := merged.Children(root.ID)
+		if len(children) != 1 || !strings.HasPrefix(children[0].Names[0], "task-") {
+			t.Errorf("unexpected children of %s: %+v", root.Names[0], children)
+		}
+	}
+	if merged.TotalSteps != 4 || merged.TotalErrors != 1 || merged.TotalRetries != 2 || merged.Count(StatusSucceeded) != 4 {
+		t.Errorf("unexpected totals: %+v", merged)
+	}
+	if !merged.Start.Equal(start) || merged.Duration != 100*time.Millisecond {
+		t.Errorf("got start %v and duration %v", merged.Start, merged.Duration)
+	}
+}
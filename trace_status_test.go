@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceStatus(t *testing.T) {
+	t.Parallel()
+
+	// waitDone blocks until its context is done.
+	waitDone := func(ctx context.Context, _ *CountingFlow) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	statusOf := func(t *testing.T, trace *Trace, name string) EventStatus {
+		t.Helper()
+		event := trace.FindEvent(NameMatches(name))
+		if event == nil {
+			t.Fatalf("expected a %s event", name)
+		}
+		return event.Status
+	}
+
+	t.Run("Finished", func(t *testing.T) {
+		t.Parallel()
+		trace, _ := Traced(InParallel(Steps(
+			Named("fails", IncrementAndFail(error1)),
+			Named("cancelled", waitDone),
+			Named("ok", Increment(1)),
+			Named("timed-out", WithTimeout(time.Millisecond, waitDone)),
+			Named("panicked", RecoverPanics(PanicWith("boom"))),
+		)))(t.Context(), &CountingFlow{})
+
+		expected := map[string]EventStatus{
+			"fails":    StatusFailed,
+			"ok":       StatusSucceeded,
+			"panicked": StatusPanicked,
+		}
+		for name, status := range expected {
+			if got := statusOf(t, trace, name); got != status {
+				t.Errorf("%s: got status %q, want %q", name, got, status)
+			}
+		}
+		if got := statusOf(t, trace, "cancelled"); got != StatusCancelled {
+			t.Errorf("cancelled: got status %q", got)
+		}
+		// Depending on scheduling, "timed-out" either times out or is
+		// cancelled by "fails" first.
+		if got := statusOf(t, trace, "timed-out"); got != StatusTimedOut && got != StatusCancelled {
+			t.Errorf("timed-out: got status %q", got)
+		}
+		if trace.Count(StatusSucceeded) != 1 || trace.Count(StatusFailed) != 1 {
+			t.Errorf("unexpected status counts: %v", trace.StatusCounts)
+		}
+	})
+
+	t.Run("TimedOut", func(t *testing.T) {
+		t.Parallel()
+		trace, _ := Traced(Named("slow", WithTimeout(time.Millisecond, waitDone)))(t.Context(), &CountingFlow{})
+		if got := statusOf(t, trace, "slow"); got != StatusTimedOut {
+			t.Errorf("got status %q, want %q", got, StatusTimedOut)
+		}
+		var buf bytes.Buffer
+		if _, err := trace.WriteFlatText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "[TIMED OUT: ") {
+			t.Errorf("unexpected text output:\n%s", buf.String())
+		}
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		t.Parallel()
+		trace, err := Traced(Named("setup", Do(
+			When(CountEquals(1), Named("never", Increment(1))),
+			Unless(CountEquals(0), Named("never", Increment(1))),
+			When(CountEquals(0), Named("ran", Increment(1))),
+		)))(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		skipped := trace.Filter(HasStatus(StatusSkipped))
+		if len(skipped.Events) != 2 || skipped.Count(StatusSkipped) != 2 {
+			t.Fatalf("expected 2 skipped events, got %+v", skipped.Events)
+		}
+		for i, name := range []string{"when", "unless"} {
+			event := skipped.Events[i]
+			if event.Names[1] != name || event.SkipReason != SkipReasonCondition {
+				t.Errorf("expected a %s skip, got %+v", name, event)
+			}
+		}
+		if trace.FindEvent(NameMatches("never")) != nil {
+			t.Error("expected skipped steps not to run")
+		}
+	})
+
+	t.Run("Retried", func(t *testing.T) {
+		t.Parallel()
+		trace, err := Traced(Named("deploy", Retry(Named("flaky", FailUntilCount(3)), UpTo(3))))(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attempts := trace.Filter(NameMatches("flaky")).Events
+		if len(attempts) != 3 {
+			t.Fatalf("expected 3 attempts, got %d", len(attempts))
+		}
+		for i, want := range []EventStatus{StatusRetried, StatusRetried, StatusSucceeded} {
+			if attempts[i].Status != want || attempts[i].Attempt != i+1 {
+				t.Errorf("attempt %d: got status %q and attempt %d", i+1, attempts[i].Status, attempts[i].Attempt)
+			}
+		}
+		if trace.Count(StatusRetried) != 2 || trace.Count(StatusFailed) != 0 || trace.Count(StatusSucceeded) != 2 {
+			t.Errorf("unexpected status counts: %v", trace.StatusCounts)
+		}
+		if _, ok := trace.StatusCounts[StatusFailed]; ok {
+			t.Errorf("expected no count of failed steps, got %v", trace.StatusCounts)
+		}
+
+		var buf bytes.Buffer
+		if _, err := trace.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "[RETRIED: not ready yet] [ATTEMPT 1]") {
+			t.Errorf("unexpected text output:\n%s", buf.String())
+		}
+	})
+
+	t.Run("LastAttemptFails", func(t *testing.T) {
+		t.Parallel()
+		trace, _ := Traced(Retry(Named("broken", IncrementAndFail(error1)), UpTo(2)))(t.Context(), &CountingFlow{})
+		got := trace.Filter(HasStatus(StatusFailed, StatusRetried)).Events
+		if len(got) != 2 || got[0].Status != StatusRetried || got[1].Status != StatusFailed {
+			t.Errorf("expected a retried and then a failed attempt, got %+v", got)
+		}
+	})
+
+	t.Run("Streamed", func(t *testing.T) {
+		t.Parallel()
+		// Streamed events have the same final status as those in memory.
+		var stream bytes.Buffer
+		step := Named("deploy", Retry(Named("flaky", FailUntilCount(2)), UpTo(2)))
+		trace, _ := Traced(step, WithStreamTo(&stream))(t.Context(), &CountingFlow{})
+		read, err := ReadTrace(&stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(read.StatusCounts, trace.StatusCounts) {
+			t.Errorf("got status counts %v from the stream, want %v", read.StatusCounts, trace.StatusCounts)
+		}
+		for i, event := range read.Events {
+			if event.Status != trace.Events[i].Status {
+				t.Errorf("event %d: got status %q from the stream, want %q", i, event.Status, trace.Events[i].Status)
+			}
+		}
+		if trace.Count(StatusRetried) != 1 || trace.Count(StatusSucceeded) != 2 {
+			t.Errorf("unexpected status counts: %v", trace.StatusCounts)
+		}
+	})
+
+	t.Run("NestedNotMarked", func(t *testing.T) {
+		t.Parallel()
+		// Only the events run directly by the retried step are marked; the
+		// inner event belongs to its own, unretried, parent.
+		trace, _ := Traced(Retry(Named("outer", Named("inner", IncrementAndFail(error1))), UpTo(2)))(t.Context(), &CountingFlow{})
+		if trace.Count(StatusRetried) != 1 || trace.Count(StatusFailed) != 3 {
+			t.Errorf("unexpected status counts: %v", trace.StatusCounts)
+		}
+	})
+}
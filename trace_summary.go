@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+)
+
+// GroupBy returns the key by which an event is grouped, for example in
+// [Trace.Summarize]. Events with an empty key are left out.
+type GroupBy func(event TraceEvent) string
+
+// ByPath groups events by their full dotted path, such as "deploy.migrate".
+func ByPath() GroupBy {
+	return func(event TraceEvent) string {
+		return strings.Join(event.Names, ".")
+	}
+}
+
+// ByName groups events by their step name, the last element of their path, so
+// that steps with the same name in different places are grouped together.
+func ByName() GroupBy {
+	return func(event TraceEvent) string {
+		if len(event.Names) == 0 {
+			return ""
+		}
+		return event.Names[len(event.Names)-1]
+	}
+}
+
+// IgnoringIndices groups events as groupBy does, but with the numbers that
+// look like indices replaced by "#", so that steps named after the index of
+// the item they process, such as "item-17", are grouped together as
+// "item-#".
+//
+// A number looks like an index if it starts the key or follows a separator:
+// '-', '_', '.', or '['. Numbers within words, as in "ipv4" or "s3-upload",
+// are kept.
+//
+// Example:
+//
+//	summary := trace.Summarize(flow.IgnoringIndices(flow.ByPath()))
+func IgnoringIndices(groupBy GroupBy) GroupBy {
+	return func(event TraceEvent) string {
+		return replaceIndices(groupBy(event))
+	}
+}
+
+// replaceIndices replaces every run of digits in s that starts s or follows
+// a separator with "#".
+func replaceIndices(s string) string {
+	var b strings.Builder
+	// index is whether the current rune may start an index, and inIndex
+	// whether it is within one.
+	index, inIndex := true, false
+	for _, r := range s {
+		if unicode.IsDigit(r) && (index || inIndex) {
+			if !inIndex {
+				b.WriteByte('#')
+			}
+			index, inIndex = false, true
+			continue
+		}
+		index, inIndex = strings.ContainsRune("-_.[", r), false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Summary holds statistics for groups of trace events, from
+// [Trace.Summarize].
+type Summary struct {
+	// Groups holds the statistics of each group, by descending total
+	// duration.
+	Groups []StepSummary `json:"groups"`
+}
+
+// StepSummary holds statistics for a group of trace events.
+//
+// Durations are computed over the events that ran; skipped events are only
+// counted in Skipped.
+type StepSummary struct {
+	// Key identifies the group (see [GroupBy]).
+	Key string `json:"key"`
+
+	// Count is the number of events that ran.
+	Count int `json:"count"`
+
+	// Skipped is the number of events that were skipped without running.
+	Skipped int `json:"skipped,omitempty"`
+
+	// Total is the sum of the durations.
+	Total time.Duration `json:"total"`
+
+	// Min, Max, and Mean are the shortest, longest, and mean durations.
+	Min  time.Duration `json:"min"`
+	Max  time.Duration `json:"max"`
+	Mean time.Duration `json:"mean"`
+
+	// P50, P90, and P99 are percentiles of the durations, by the
+	// nearest-rank method.
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+
+	// Errors is the number of events with an error, and ErrorRate the
+	// fraction of Count that they make up.
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// Summarize aggregates the trace's events into groups by groupBy, computing
+// the count, duration statistics, and error rate of each group. Groups are
+// ordered by descending total duration, so the steps that took the most time
+// come first. Events that are not steps (see [TraceEvent.Kind]) are left out.
+//
+// Example:
+//
+//	summary := trace.Summarize(flow.ByName())
+//	for _, group := range summary.Groups[:min(5, len(summary.Groups))] {
+//	    fmt.Printf("%s: %d runs, p99 %v\n", group.Key, group.Count, group.P99)
+//	}
+func (t *Trace) Summarize(groupBy GroupBy) *Summary {
+	groups := make(map[string]*StepSummary)
+	durations := make(map[string][]time.Duration)
+	var keys []string
+	for _, event := range t.Events {
+		if event.Kind != KindStep {
+			continue
+		}
+		key := groupBy(event)
+		if key == "" {
+			continue
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &StepSummary{Key: key}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		if event.SkipReason != "" {
+			group.Skipped++
+			continue
+		}
+		group.Count++
+		group.Total += event.Duration
+		if event.Error != "" {
+			group.Errors++
+		}
+		durations[key] = append(durations[key], event.Duration)
+	}
+
+	summary := &Summary{Groups: make([]StepSummary, 0, len(keys))}
+	for _, key := range keys {
+		group := groups[key]
+		if d := durations[key]; len(d) > 0 {
+			slices.Sort(d)
+			group.Min = d[0]
+			group.Max = d[len(d)-1]
+			group.Mean = group.Total / time.Duration(len(d))
+			group.P50 = percentile(d, 50)
+			group.P90 = percentile(d, 90)
+			group.P99 = percentile(d, 99)
+			group.ErrorRate = float64(group.Errors) / float64(group.Count)
+		}
+		summary.Groups = append(summary.Groups, *group)
+	}
+	slices.SortStableFunc(summary.Groups, func(a, b StepSummary) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+	return summary
+}
+
+// percentile returns the pth percentile of sorted durations by the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// WriteTo serializes the summary as JSON to the given writer.
+func (s *Summary) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal summary: %w", err)
+	}
+	n, err := w.Write(append(data, '\n'))
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write summary: %w", err)
+	}
+	return int64(n), nil
+}
+
+// WriteText outputs the summary as a human-readable table.
+//
+// Example output:
+//
+//	STEP            COUNT  TOTAL  MIN    MEAN   P50    P90    P99    MAX    ERRORS
+//	process.item    100    12.5s  80ms   125ms  110ms  190ms  420ms  450ms  3 (3.0%)
+//	process.verify  1      1.2s   1.2s   1.2s   1.2s   1.2s   1.2s   1.2s   0
+func (s *Summary) WriteText(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	tw := tabwriter.NewWriter(cw, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tCOUNT\tTOTAL\tMIN\tMEAN\tP50\tP90\tP99\tMAX\tERRORS")
+	for _, group := range s.Groups {
+		count := fmt.Sprint(group.Count)
+		if group.Skipped > 0 {
+			count += fmt.Sprintf(" (+%d skipped)", group.Skipped)
+		}
+		errs := fmt.Sprint(group.Errors)
+		if group.Errors > 0 {
+			errs += fmt.Sprintf(" (%.1f%%)", 100*group.ErrorRate)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			group.Key, count,
+			formatDuration(group.Total), formatDuration(group.Min),
+			formatDuration(group.Mean), formatDuration(group.P50),
+			formatDuration(group.P90), formatDuration(group.P99),
+			formatDuration(group.Max), errs,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return cw.n, fmt.Errorf("failed to write summary: %w", err)
+	}
+	return cw.n, nil
+}
+
+// WriteSummary outputs a table summarizing the trace's events by path (see
+// [Trace.Summarize] and [Summary.WriteText]).
+func (t *Trace) WriteSummary(w io.Writer) (int64, error) {
+	return t.Summarize(ByPath()).WriteText(w)
+}
+
+// WriteSummaryTo returns a Step that writes a summary table of a trace.
+//
+// This enables natural composition with Spawn:
+//
+//	workflow := flow.Spawn(
+//	    flow.Traced(myWorkflow),
+//	    flow.WriteSummaryTo(os.Stdout),
+//	)
+func WriteSummaryTo(w io.Writer) Step[*Trace] {
+	return func(ctx context.Context, trace *Trace) error {
+		_, err := trace.WriteSummary(w)
+		return err
+	}
+}
+
+// formatDuration formats a duration for a table, rounded to a precision
+// suited to its magnitude.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceSummarize(t *testing.T) {
+	t.Parallel()
+
+	// The trace has 100 items taking 1ms to 100ms, every tenth failing, and
+	// one slow verify step.
+	trace := &Trace{}
+	for i := 1; i <= 100; i++ {
+		event := TraceEvent{
+			Names:    []string{"process", fmt.Sprintf("item-%d", i)},
+			Duration: time.Duration(i) * time.Millisecond,
+		}
+		if i%10 == 0 {
+			event.Error = "boom"
+		}
+		trace.Events = append(trace.Events, event)
+	}
+	trace.Events = append(trace.Events,
+		TraceEvent{Names: []string{"process", "verify"}, Duration: 10 * time.Second},
+		TraceEvent{Names: []string{"process", "verify"}, SkipReason: SkipReasonDryRun, Status: StatusSkipped},
+	)
+
+	t.Run("IgnoringIndices", func(t *testing.T) {
+		t.Parallel()
+		summary := trace.Summarize(IgnoringIndices(ByPath()))
+		if len(summary.Groups) != 2 {
+			t.Fatalf("expected 2 groups, got %+v", summary.Groups)
+		}
+
+		verify := summary.Groups[0]
+		if verify.Key != "process.verify" || verify.Count != 1 || verify.Skipped != 1 || verify.P99 != 10*time.Second {
+			t.Errorf("unexpected verify group: %+v", verify)
+		}
+
+		items := summary.Groups[1]
+		want := StepSummary{
+			Key:       "process.item-#",
+			Count:     100,
+			Total:     5050 * time.Millisecond,
+			Min:       time.Millisecond,
+			Max:       100 * time.Millisecond,
+			Mean:      50500 * time.Microsecond,
+			P50:       50 * time.Millisecond,
+			P90:       90 * time.Millisecond,
+			P99:       99 * time.Millisecond,
+			Errors:    10,
+			ErrorRate: 0.1,
+		}
+		if items != want {
+			t.Errorf("got %+v, want %+v", items, want)
+		}
+	})
+
+	t.Run("IndexLikeNumbers", func(t *testing.T) {
+		t.Parallel()
+		testCases := []struct {
+			key, want string
+		}{
+			{"item-17", "item-#"},
+			{"shard_3.run", "shard_#.run"},
+			{"users[42]", "users[#]"},
+			{"batch.7.save", "batch.#.save"},
+			{"12", "#"},
+			{"ipv4", "ipv4"},
+			{"ipv6", "ipv6"},
+			{"s3-upload-2", "s3-upload-#"},
+		}
+		for _, tc := range testCases {
+			if got := replaceIndices(tc.key); got != tc.want {
+				t.Errorf("replaceIndices(%q): got %q, want %q", tc.key, got, tc.want)
+			}
+		}
+
+		// Steps differing in a number within a word stay separate.
+		ips := &Trace{Events: []TraceEvent{
+			{Names: []string{"ipv4"}, Duration: time.Millisecond},
+			{Names: []string{"ipv6"}, Duration: time.Millisecond},
+		}}
+		if groups := ips.Summarize(IgnoringIndices(ByName())).Groups; len(groups) != 2 {
+			t.Errorf("expected ipv4 and ipv6 in separate groups, got %+v", groups)
+		}
+	})
+
+	t.Run("ByName", func(t *testing.T) {
+		t.Parallel()
+		summary := trace.Summarize(ByName())
+		if len(summary.Groups) != 101 || summary.Groups[0].Key != "verify" || summary.Groups[1].Key != "item-100" {
+			t.Errorf("expected a group per item, slowest first, got %d groups", len(summary.Groups))
+		}
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		t.Parallel()
+		summary := trace.Summarize(IgnoringIndices(ByPath()))
+
+		var text bytes.Buffer
+		n, err := summary.WriteText(&text)
+		if err != nil || n != int64(text.Len()) {
+			t.Fatalf("got %d bytes and %v, wrote %d", n, err, text.Len())
+		}
+		lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "STEP") {
+			t.Fatalf("unexpected table:\n%s", text.String())
+		}
+		for _, field := range []string{"process.item-#", "100", "5.05s", "50.5ms", "99ms", "10 (10.0%)"} {
+			if !strings.Contains(lines[2], field) {
+				t.Errorf("expected %q in %q", field, lines[2])
+			}
+		}
+		if !strings.Contains(lines[1], "1 (+1 skipped)") {
+			t.Errorf("expected skipped count in %q", lines[1])
+		}
+
+		var data bytes.Buffer
+		if _, err := summary.WriteTo(&data); err != nil {
+			t.Fatal(err)
+		}
+		var decoded Summary
+		if err := json.Unmarshal(data.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded.Groups) != 2 || decoded.Groups[1] != summary.Groups[1] {
+			t.Errorf("unexpected JSON round trip: %s", data.String())
+		}
+	})
+
+	t.Run("Traced", func(t *testing.T) {
+		t.Parallel()
+		trace, err := Traced(Named("outer", Do(
+			Named("step", Increment(1)),
+			Named("step", Increment(1)),
+		)))(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := trace.WriteSummary(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "outer.step ") {
+			t.Errorf("unexpected summary:\n%s", buf.String())
+		}
+		if summary := trace.Summarize(ByPath()); summary.Groups[1].Count != 2 {
+			t.Errorf("expected 2 steps, got %+v", summary.Groups)
+		}
+	})
+}
@@ -837,3 +837,97 @@ func TestFindEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestTracedPanics(t *testing.T) {
+	t.Parallel()
+
+	// expectPanicked checks which events are marked as panicked and their errors.
+	expectPanicked := func(panicked map[string]string) traceValidator {
+		return func(trace *Trace) error {
+			for _, event := range trace.Events {
+				name := event.Names[len(event.Names)-1]
+				wantErr, want := panicked[name]
+				if event.Panicked != want {
+					return fmt.Errorf("event %q: got panicked %v, want %v", name, event.Panicked, want)
+				}
+				if want && event.Error != wantErr {
+					return fmt.Errorf("event %q: got error %q, want %q", name, event.Error, wantErr)
+				}
+			}
+			return nil
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		step       Step[*CountingFlow]
+		validators []traceValidator
+	}{
+		{
+			name: "Recovered",
+			step: InParallelWith(
+				ParallelOptions{RecoverPanics: true},
+				Steps(
+					Named("outer", Named("inner", PanicWith("boom"))),
+					Named("other", Increment(1)),
+				),
+			),
+			validators: []traceValidator{
+				expectEvents(3),
+				expectErrorCount(2),
+				expectPanicked(map[string]string{"inner": "panic recovered: boom"}),
+			},
+		},
+		{
+			name: "Unrecovered",
+			step: RecoverPanics(Named("outer", Named("inner", PanicWith("boom")))),
+			validators: []traceValidator{
+				expectEvents(2),
+				expectErrorCount(2),
+				expectPanicked(map[string]string{
+					"outer": "panic",
+					"inner": "panic",
+				}),
+			},
+		},
+		{
+			name: "ManualRecovery",
+			step: Named("outer", RecoverPanics(PanicWith("boom"))),
+			validators: []traceValidator{
+				expectEvents(1),
+				expectErrorCount(1),
+				expectPanicked(map[string]string{}),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			runTraceTest(t, tc.step, tc.validators...)
+		})
+	}
+
+	t.Run("FilterAndText", func(t *testing.T) {
+		t.Parallel()
+		trace, _ := Traced(DoWith(
+			Options{RecoverPanics: true, JoinErrors: true},
+			Named("fails", IncrementAndFail(error1)),
+			Named("panics", PanicWith("boom")),
+		))(t.Context(), &CountingFlow{})
+
+		filtered := trace.Filter(Panicked())
+		if len(filtered.Events) != 1 || filtered.Events[0].Names[0] != "panics" {
+			t.Errorf("expected only the panicking event, got %v", filtered.Events)
+		}
+
+		var buf bytes.Buffer
+		if _, err := trace.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if !strings.Contains(out, "[ERROR: error 1]") || !strings.Contains(out, "[PANIC: panic recovered: boom]") {
+			t.Errorf("unexpected text output:\n%s", out)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"slices"
+)
+
+// Roots returns the events that have no parent within the trace, in start
+// order: the outermost Named steps, or, in a filtered trace, the events whose
+// parent was filtered out.
+//
+// The returned pointers refer to the trace's Events.
+func (t *Trace) Roots() []*TraceEvent {
+	ids := make(map[int]bool, len(t.Events))
+	for _, event := range t.Events {
+		if event.ID != 0 {
+			ids[event.ID] = true
+		}
+	}
+	var roots []*TraceEvent
+	for i := range t.Events {
+		if !ids[t.Events[i].ParentID] {
+			roots = append(roots, &t.Events[i])
+		}
+	}
+	sortByStart(roots)
+	return roots
+}
+
+// Children returns the events whose parent is the event with the given ID,
+// in start order.
+//
+// The returned pointers refer to the trace's Events.
+func (t *Trace) Children(id int) []*TraceEvent {
+	if id == 0 {
+		return nil
+	}
+	var children []*TraceEvent
+	for i := range t.Events {
+		if t.Events[i].ParentID == id {
+			children = append(children, &t.Events[i])
+		}
+	}
+	sortByStart(children)
+	return children
+}
+
+// walk visits the events of the trace depth-first in tree order, passing each
+// event's depth in the tree (0 for roots).
+func (t *Trace) walk(visit func(event *TraceEvent, depth int) error) error {
+	children := make(map[int][]*TraceEvent)
+	for i := range t.Events {
+		if parent := t.Events[i].ParentID; parent != 0 {
+			children[parent] = append(children[parent], &t.Events[i])
+		}
+	}
+	for _, events := range children {
+		sortByStart(events)
+	}
+
+	// visited guards against cycles in traces read from elsewhere.
+	visited := make(map[*TraceEvent]bool, len(t.Events))
+	var walk func(event *TraceEvent, depth int) error
+	walk = func(event *TraceEvent, depth int) error {
+		if visited[event] {
+			return nil
+		}
+		visited[event] = true
+		if err := visit(event, depth); err != nil {
+			return err
+		}
+		if event.ID == 0 {
+			return nil
+		}
+		for _, child := range children[event.ID] {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range t.Roots() {
+		if err := walk(root, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortByStart sorts events by start time, keeping events that started at
+// the same time in recording order.
+func sortByStart(events []*TraceEvent) {
+	slices.SortStableFunc(events, func(a, b *TraceEvent) int {
+		return a.Start.Compare(b.Start)
+	})
+}
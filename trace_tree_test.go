@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTraceTree(t *testing.T) {
+	t.Parallel()
+
+	// gate blocks until released, so that parallel steps interleave.
+	gate := func(release <-chan struct{}) Step[*CountingFlow] {
+		return func(context.Context, *CountingFlow) error {
+			<-release
+			return nil
+		}
+	}
+
+	t.Run("ParallelInterleaving", func(t *testing.T) {
+		t.Parallel()
+		releaseA := make(chan struct{})
+		releaseB := make(chan struct{})
+		workflow := InParallel(Steps(
+			Named("a", Do(gate(releaseA), Named("child", Increment(1)))),
+			Named("b", Do(
+				func(context.Context, *CountingFlow) error {
+					// Let a's child start only after b has started, and b's
+					// child after a's.
+					close(releaseA)
+					return nil
+				},
+				Sleep[*CountingFlow](5*time.Millisecond),
+				Named("child", gate(releaseB)),
+			)),
+			Named("c", func(context.Context, *CountingFlow) error {
+				close(releaseB)
+				return nil
+			}),
+		))
+		trace, err := Traced(workflow)(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		roots := trace.Roots()
+		if len(roots) != 3 {
+			t.Fatalf("expected 3 roots, got %d", len(roots))
+		}
+		for _, root := range roots {
+			if root.ParentID != 0 || root.ID == 0 {
+				t.Errorf("expected root %v to have an ID and no parent", root.Names)
+			}
+			children := trace.Children(root.ID)
+			if root.Names[0] == "c" {
+				if len(children) != 0 {
+					t.Errorf("expected c to have no children, got %d", len(children))
+				}
+				continue
+			}
+			if len(children) != 1 || children[0].Names[0] != root.Names[0] {
+				t.Errorf("expected %s to have its own child, got %+v", root.Names[0], children)
+			}
+		}
+
+		// Each child is rendered directly under its own parent.
+		var buf bytes.Buffer
+		if _, err := trace.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		if len(lines) != 5 {
+			t.Fatalf("expected 5 lines, got:\n%s", buf.String())
+		}
+		for i, line := range lines {
+			if bytes.HasPrefix(line, []byte("  child")) {
+				parent := lines[i-1]
+				if !bytes.HasPrefix(parent, []byte("a ")) && !bytes.HasPrefix(parent, []byte("b ")) {
+					t.Errorf("expected child under a or b, got:\n%s", buf.String())
+				}
+			}
+		}
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		t.Parallel()
+		workflow := Named("outer", Do(
+			Named("middle", Named("inner", Increment(1))),
+		))
+		trace, err := Traced(workflow)(t.Context(), &CountingFlow{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		filtered := trace.Filter(NameMatches("inner"))
+		roots := filtered.Roots()
+		if len(roots) != 1 || roots[0].Names[2] != "inner" {
+			t.Errorf("expected inner to become a root, got %+v", roots)
+		}
+		var buf bytes.Buffer
+		if _, err := filtered.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), []byte("inner (")) {
+			t.Errorf("expected inner at the top level, got %q", buf.String())
+		}
+	})
+
+	t.Run("NoIDs", func(t *testing.T) {
+		t.Parallel()
+		trace := &Trace{Events: []TraceEvent{
+			{Names: []string{"a"}},
+			{Names: []string{"a", "b"}},
+		}}
+		if len(trace.Roots()) != 2 || trace.Children(0) != nil {
+			t.Error("expected events without IDs to be roots without children")
+		}
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		t.Parallel()
+		trace := &Trace{Events: []TraceEvent{
+			{ID: 1, Names: []string{"a"}},
+			{ID: 1, ParentID: 1, Names: []string{"a", "b"}},
+		}}
+		var buf bytes.Buffer
+		if _, err := trace.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if got := bytes.Count(buf.Bytes(), []byte("\n")); got != 2 {
+			t.Errorf("expected each event once, got:\n%s", buf.String())
+		}
+	})
+}